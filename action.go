@@ -1,9 +1,16 @@
 package amigo
 
 import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"github.com/tqcenglish/amigo-go/pkg/parse"
 	"github.com/tqcenglish/amigo-go/utils"
 )
 
@@ -32,29 +39,59 @@ func (a *amiAdapter) login() error {
 	var action = map[string]string{
 		"Action":   "Login",
 		"Username": a.username,
-		"Secret":   a.password,
 	}
 
-	utils.Log.Infof("ami login action: %+v", action)
+	authType := a.settings.authType()
+	switch authType {
+	case AuthMD5:
+		key, err := a.challenge()
+		if err != nil {
+			return err
+		}
+		action["AuthType"] = "MD5"
+		action["Key"] = key
+	default:
+		action["Secret"] = a.password
+	}
+
+	utils.Log.Infof("ami login username: %s authtype: %s", a.username, authType)
 	if data, _, err := a.amigo.Send(action); err != nil {
 		return err
 	} else if data["Response"] != "Success" && data["Message"] != "Authentication accepted" {
-		utils.Log.Errorf("ami login failure by username:%s password:%s", a.username, a.password)
+		utils.Log.Errorf("ami login failure by username:%s", a.username)
 		return errors.New(data["Message"])
 	}
 	return nil
 }
 
+// challenge 请求 MD5 challenge 并计算 md5(challenge + secret)
+func (a *amiAdapter) challenge() (string, error) {
+	var action = map[string]string{
+		"Action":   "Challenge",
+		"AuthType": "MD5",
+	}
+	data, _, err := a.amigo.Send(action)
+	if err != nil {
+		return "", err
+	}
+	if data["Response"] != "Success" || data["Challenge"] == "" {
+		return "", errors.New("ami challenge failure: " + data["Message"])
+	}
+
+	sum := md5.Sum([]byte(data["Challenge"] + a.password))
+	return hex.EncodeToString(sum[:]), nil
+}
+
 // SIPPeersRes respons
 // Response: Success
 // ActionID: 043a71d0-4ee1-419f-9b0b-70751d6274c3
 // EventList: start
 // Message: Peer status list will follow
 type SIPPeersRes struct {
-	Response  string `json:"response"`
-	ActionID  string `json:"action_id"`
-	EventList string `json:"event_list"`
-	Message   string `json:"message"`
+	Response  string `ami:"Response"`
+	ActionID  string `ami:"ActionID"`
+	EventList string `ami:"EventList"`
+	Message   string `ami:"Message"`
 }
 
 // SIPpeersEvent event
@@ -78,15 +115,15 @@ type SIPPeersRes struct {
 // Description:
 // Accountcode:
 type SIPpeersEvent struct {
-	Event          string `json:"event"`
-	ActionID       string `json:"action_id"`
-	Channeltype    string `json:"channel_type"`
-	ObjectName     string `json:"object_name"`
-	ChanObjectType string `json:"chan_object_type"`
-	IPaddress      string `json:"ip_address"`
-	IPport         string `json:"ip_port"`
-	Status         string `json:"status"`
-	ACL            string `json:"acl"`
+	Event          string `ami:"Event"`
+	ActionID       string `ami:"ActionID"`
+	Channeltype    string `ami:"Channeltype"`
+	ObjectName     string `ami:"ObjectName"`
+	ChanObjectType string `ami:"ChanObjectType"`
+	IPaddress      string `ami:"IPaddress"`
+	IPport         string `ami:"IPport"`
+	Status         string `ami:"Status"`
+	ACL            string `ami:"ACL"`
 }
 
 // SIPpeers sip show peers
@@ -101,22 +138,162 @@ func (a *Amigo) SIPpeers() (response *SIPPeersRes, events []*SIPpeersEvent, err
 	}
 
 	response = &SIPPeersRes{}
-	for k, v := range responseMap {
-		if err := utils.SetField(response, k, v); err != nil {
-			utils.Log.Errorf("Response SetField error %+v", err)
-		}
+	if err := parse.Decode(responseMap, response); err != nil {
+		utils.Log.Errorf("Response decode error %+v", err)
 	}
 
 	events = make([]*SIPpeersEvent, 0)
 	for _, eventMap := range eventsMapArray {
 		event := &SIPpeersEvent{}
-		for k, v := range eventMap.Data {
-			if err := utils.SetField(event, k, v); err != nil {
-				utils.Log.Errorf("Event SetField error %+v", err)
-				continue
-			}
+		if err := parse.DecodeMessage(eventMap.Message, event); err != nil {
+			utils.Log.Errorf("Event decode error %+v", err)
+			continue
 		}
 		events = append(events, event)
 	}
 	return response, events, nil
 }
+
+// Action 按顺序编码的 action, 头可重复出现
+//
+//	action := amigo.NewAction("Originate").
+//		Add("Channel", "PJSIP/1001").
+//		Variable("CALLERID(num)", "1001").
+//		Variable("ORIGIN", "crm")
+type Action struct {
+	headers []parse.Header
+}
+
+// NewAction 新建以 "Action: name" 开头的 action
+func NewAction(name string) *Action {
+	return &Action{headers: []parse.Header{{Key: "Action", Value: name}}}
+}
+
+// ActionFromMap 由 Send 使用的 map 构建 action, Action 头在前, 其余按头名排序
+func ActionFromMap(m map[string]string) *Action {
+	action := NewAction(m["Action"])
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		if key != "Action" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		action.Add(key, m[key])
+	}
+	return action
+}
+
+// Add 追加一个头, 同名头可重复
+func (a *Action) Add(key, value string) *Action {
+	a.headers = append(a.headers, parse.Header{Key: key, Value: value})
+	return a
+}
+
+// Set 设置头的值, 替换已有的同名头(不区分大小写), 不存在时追加
+func (a *Action) Set(key, value string) *Action {
+	headers := a.headers[:0:0]
+	set := false
+	for _, header := range a.headers {
+		if !strings.EqualFold(header.Key, key) {
+			headers = append(headers, header)
+		} else if !set {
+			headers = append(headers, parse.Header{Key: header.Key, Value: value})
+			set = true
+		}
+	}
+	if !set {
+		headers = append(headers, parse.Header{Key: key, Value: value})
+	}
+	a.headers = headers
+	return a
+}
+
+// Variable 追加一个 "Variable: name=value" 头
+func (a *Action) Variable(name, value string) *Action {
+	return a.Add("Variable", name+"="+value)
+}
+
+// Name 返回 action 名
+func (a *Action) Name() string {
+	return a.Get("Action")
+}
+
+// Get 返回第一个匹配的头, 不区分大小写
+func (a *Action) Get(key string) string {
+	for _, header := range a.headers {
+		if strings.EqualFold(header.Key, key) {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+// Headers 按顺序返回全部头
+func (a *Action) Headers() []parse.Header {
+	return append([]parse.Header(nil), a.headers...)
+}
+
+// Validate 检查头名和值, 含有 CR/LF 的值会在协议中注入额外的头, 返回 utils.ErrInvalidAction
+func (a *Action) Validate() error {
+	if a.Name() == "" {
+		return fmt.Errorf("%w: missing Action header", utils.ErrInvalidAction)
+	}
+	for _, header := range a.headers {
+		if header.Key == "" || strings.ContainsAny(header.Key, ":\r\n") {
+			return fmt.Errorf("%w: header name %q", utils.ErrInvalidAction, header.Key)
+		}
+		if strings.ContainsAny(header.Value, "\r\n") {
+			return fmt.Errorf("%w: header %s contains CR/LF", utils.ErrInvalidAction, header.Key)
+		}
+	}
+	return nil
+}
+
+// String 按 AMI 格式编码, 以空行结束, 不做检查
+func (a *Action) String() string {
+	var b strings.Builder
+	for _, header := range a.headers {
+		b.WriteString(header.Key)
+		b.WriteString(": ")
+		b.WriteString(header.Value)
+		b.WriteString(utils.EOL)
+	}
+	b.WriteString(utils.EOL)
+	return b.String()
+}
+
+func (a *Action) clone() *Action {
+	return &Action{headers: a.Headers()}
+}
+
+// masked 返回隐藏了 Secret 的副本, 用于日志和记录
+func (a *Action) masked() *Action {
+	if a.Get("Secret") == "" {
+		return a
+	}
+	return a.clone().Set("Secret", "********")
+}
+
+// SendAction 与 SendContext 相同, 按 action 中头的顺序发送
+// action 已设置 ActionID 时沿用, 否则自动生成, 不会修改 action
+func (a *Amigo) SendAction(ctx context.Context, action *Action) (data map[string]string, event []parse.Event, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return a.send(ctx, action).WaitContext(ctx)
+}
+
+// request 发送 action 并等待, Response: Error 时返回 *ActionError
+func (a *Amigo) request(ctx context.Context, action *Action) (map[string]string, []parse.Event, error) {
+	data, events, err := a.SendAction(ctx, action)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := responseError(action.Name(), data); err != nil {
+		return data, events, err
+	}
+	return data, events, nil
+}
@@ -1,6 +1,7 @@
 package amigo
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
@@ -9,26 +10,33 @@ import (
 	"time"
 
 	"github.com/tqcenglish/amigo-go/pkg"
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/pkg/record"
 	"github.com/tqcenglish/amigo-go/utils"
 )
 
-type amiAdapter struct {
-	id string
+// queuedAction 等待 writer 发送的 action, connID 为提交时的连接
+type queuedAction struct {
+	connID string
+	action *Action
+}
 
-	received chan string
-	msg      chan string
+type amiAdapter struct {
+	id       string
+	settings *Settings
 
 	username string
 	password string
 
 	connected bool
+	// reconnect 登录失败后为 false, 由 amigo.mutex 保护
 	reconnect bool
 	chanStop  chan struct{}
 
 	dialString  string
 	dialTimeout time.Duration
 
-	actionsChan chan map[string]string
+	actionsChan chan queuedAction
 	mutex       *sync.RWMutex
 	amigo       *Amigo
 
@@ -37,6 +45,7 @@ type amiAdapter struct {
 
 func newAMIAdapter(s *Settings, eventEmitter pkg.EventEmmiter, amigo *Amigo) {
 	adapter := &amiAdapter{
+		settings:   s,
 		dialString: fmt.Sprintf("%s:%s", s.Host, s.Port),
 		username:   s.Username,
 		password:   s.Password,
@@ -44,23 +53,19 @@ func newAMIAdapter(s *Settings, eventEmitter pkg.EventEmmiter, amigo *Amigo) {
 		reconnect: true,
 		chanStop:  make(chan struct{}),
 
-		received: make(chan string, 1024),
-		msg:      make(chan string, 1024),
-
 		dialTimeout:  s.DialTimeout,
 		mutex:        &sync.RWMutex{},
 		eventEmitter: eventEmitter,
 		amigo:        amigo,
 
-		actionsChan: make(chan map[string]string),
+		actionsChan: make(chan queuedAction, 1024),
 	}
 
 	amigo.mutex.Lock()
 	amigo.ami = adapter
 	amigo.mutex.Unlock()
 
-	go adapter.initializeSocket()
-	go amigo.handleMsg(adapter.chanStop)
+	amigo.spawn(adapter.initializeSocket)
 }
 
 func (a *amiAdapter) initializeSocket() {
@@ -82,8 +87,8 @@ func (a *amiAdapter) initializeSocket() {
 	}
 	defer conn.Close()
 
-	greetings := make([]byte, 100)
-	n, err := conn.Read(greetings)
+	scanner := parse.NewFrameScanner(conn, a.settings.MaxFrameSize)
+	greetings, err := scanner.ReadLine()
 	if err != nil {
 		utils.Log.Errorf("ami read socket %s", err)
 		a.eventEmitter.Emit("AMI_Connect", pkg.Disconnect_Network_Error)
@@ -92,21 +97,17 @@ func (a *amiAdapter) initializeSocket() {
 		return
 	}
 
-	if n > 2 {
-		greetings = greetings[:n-2]
-	}
-
 	a.mutex.Lock()
 	a.connected = true
 	a.mutex.Unlock()
 
-	utils.Log.Infof("ami connect: %s", string(greetings))
+	utils.Log.Infof("ami connect: %s", greetings)
 
 	var wg sync.WaitGroup
 	wg.Add(3)
 	go func() {
 		defer wg.Done()
-		a.reader(conn, a.chanStop, readErrChan)
+		a.reader(scanner, a.chanStop, readErrChan)
 	}()
 
 	go func() {
@@ -118,7 +119,9 @@ func (a *amiAdapter) initializeSocket() {
 		defer wg.Done()
 		if err := a.login(); err != nil {
 			utils.Log.Errorf("ami login %s", pkg.Connect_Password_Error)
+			a.amigo.mutex.Lock()
 			a.reconnect = false
+			a.amigo.mutex.Unlock()
 			return
 		}
 		a.eventEmitter.Emit("AMI_Connect", pkg.Connect_OK)
@@ -132,9 +135,14 @@ func (a *amiAdapter) initializeSocket() {
 		utils.Log.Error("get write err chan message")
 	case err = <-pingErrChan:
 		utils.Log.Error("get ping err chan message")
+	case <-a.amigo.closed:
+		err = utils.ErrClosed
 	}
 	utils.Log.Errorf("ami read/write/ping socket %s", err.Error())
 	close(a.chanStop)
+	// 关闭连接使 reader 退出阻塞的 Read
+	conn.Close()
+	a.amigo.failPending(utils.ErrNotConnected)
 
 	wg.Wait()
 
@@ -152,73 +160,35 @@ func (a *amiAdapter) online() bool {
 }
 
 func (a *amiAdapter) openConnection() (net.Conn, error) {
+	if a.settings.TLS {
+		return a.dialTLS()
+	}
 	return net.DialTimeout("tcp", a.dialString, a.dialTimeout)
 }
 
-func (a *amiAdapter) reader(conn net.Conn, stop <-chan struct{}, readErrChan chan error) {
-	go func() {
-		var result []byte
-		for {
-			select {
-			case <-stop:
-				utils.Log.Info("stop read parse goroutine")
-				return
-			case msg := <-a.received:
-				result = append(result, []byte(msg)...)
-				for {
-					index := strings.Index(string(result), utils.EOM)
-					if index != -1 {
-						// 获取结束位置
-						endIndex := index + len(utils.EOM)
-						skippedEolChars := 0
-						nextIndex := endIndex + skippedEolChars + 1
-						for nextIndex < len(result) {
-							nextChar := result[nextIndex]
-							if nextChar == '\r' || nextChar == '\n' {
-								skippedEolChars++
-								continue
-							}
-							break
-						}
-						a.msg <- string(result[:index])
-						result = result[endIndex:]
-						continue
-					}
-					break
-				}
+func (a *amiAdapter) reader(scanner *parse.FrameScanner, stop <-chan struct{}, readErrChan chan error) {
+	for {
+		frame, err := scanner.Next()
+		if err == parse.ErrFrameTooLarge {
+			utils.Log.Errorf("ami read %s, discarded", err)
+			continue
+		}
+		if err != nil {
+			if err == io.EOF {
+				utils.Log.Error("conn Read io.EOF")
 			}
-
+			readErrChan <- err
+			return
 		}
 
-	}()
-
-	// 持续读取数据
-	buf := make([]byte, 1024*4)
-	for {
+		a.record(record.In, frame)
 		select {
+		case a.amigo.msg <- frame:
 		case <-stop:
 			utils.Log.Info("stop read goroutine")
 			return
-		default:
-			n, err := conn.Read(buf)
-			if err != nil {
-				if err == io.EOF {
-					utils.Log.Error("conn Read io.EOF")
-					// 如果 continue, 会一直在次循环
-					// continue
-				}
-				close(a.received)
-				readErrChan <- err
-				return
-			}
-			if n == 0 {
-				utils.Log.Error("conn Read number 0")
-			}
-			a.received <- string(buf[:n])
 		}
-
 	}
-
 }
 
 func (a *amiAdapter) writer(conn net.Conn, stop <-chan struct{}, writeErrChan chan error) {
@@ -227,13 +197,14 @@ func (a *amiAdapter) writer(conn net.Conn, stop <-chan struct{}, writeErrChan ch
 		case <-stop:
 			utils.Log.Info("stop write goroutine")
 			return
-		case action := <-a.actionsChan:
-			if action[utils.AmigoConnIDKey] != a.id {
+		case queued := <-a.actionsChan:
+			if queued.connID != a.id {
 				// action sent before reconnect, need to be ignored
 				continue
 			}
 
-			data := utils.Marshall(utils.StringMapToInterface(action))
+			data := queued.action.String()
+			a.recordAction(queued.action)
 			_, err := conn.Write([]byte(data))
 			if err != nil {
 				writeErrChan <- err
@@ -243,7 +214,30 @@ func (a *amiAdapter) writer(conn net.Conn, stop <-chan struct{}, writeErrChan ch
 	}
 }
 
-func (a *amiAdapter) exec(action map[string]string) {
-	action[utils.AmigoConnIDKey] = a.id
-	a.actionsChan <- action
+func (a *amiAdapter) record(dir record.Direction, data string) {
+	if a.settings.Recorder == nil {
+		return
+	}
+	if err := a.settings.Recorder.Record(dir, data); err != nil {
+		utils.Log.Errorf("ami record %s", err)
+	}
+}
+
+// recordAction 记录发出的 action, 不记录明文 Secret
+func (a *amiAdapter) recordAction(action *Action) {
+	if a.settings.Recorder == nil {
+		return
+	}
+	a.record(record.Out, strings.TrimSuffix(action.masked().String(), utils.EOM))
+}
+
+func (a *amiAdapter) exec(ctx context.Context, action *Action) error {
+	select {
+	case a.actionsChan <- queuedAction{connID: a.id, action: action}:
+		return nil
+	case <-a.chanStop:
+		return utils.ErrNotConnected
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
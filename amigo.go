@@ -1,13 +1,14 @@
 package amigo
 
 import (
-	"errors"
+	"context"
 	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/tqcenglish/amigo-go/pkg"
 	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/pkg/record"
 	"github.com/tqcenglish/amigo-go/utils"
 )
 
@@ -16,29 +17,90 @@ type Amigo struct {
 	settings *Settings
 	ami      *amiAdapter
 
-	eventEmitter pkg.EventEmmiter
+	eventEmitter  pkg.EventEmmiter
+	subscriptions *subscriptions
 
 	responses sync.Map
 
 	connected bool
+	loggedIn  bool
+	attempts  int
 	mutex     *sync.RWMutex
+
+	// loginHooks 登录成功后执行的 onLogin 回调
+	loginHooks watchers
+	// msg 收到的帧和 Replay 的帧, 由 handleMsg 依次处理
+	msg chan string
+
+	closed    chan struct{}
+	closeOnce sync.Once
+	// closeMutex 保证 wg.Add 不会与 Close 中的 wg.Wait 并发, 见 spawn
+	closeMutex sync.Mutex
+	wg         sync.WaitGroup
 }
 
+// AuthType 登录认证方式
+type AuthType string
+
+const (
+	// AuthDefault 未启用 TLS 时使用 AuthMD5, 否则使用 AuthPlain
+	AuthDefault AuthType = ""
+	// AuthPlain 明文发送 Secret
+	AuthPlain AuthType = "plain"
+	// AuthMD5 Challenge/Response 方式, 不发送明文 Secret
+	AuthMD5 AuthType = "md5"
+)
+
 // Settings represents connection settings for Amigo.
+// 登录后每 utils.PingInterval 发送一次 Ping 保活, 失败视为断线并按 ReconnectPolicy 重连
 type Settings struct {
 	Username string
 	Password string
 	Host     string
 	Port     string
+	AuthType AuthType
 
 	DialTimeout       time.Duration
 	ReconnectInterval time.Duration
-	Keepalive         bool
+	// Deprecated: ignored, Ping keepalive is always on
+	Keepalive bool
+
+	// ReconnectPolicy 断线重连策略, 为空时按 ReconnectInterval 固定间隔重连
+	ReconnectPolicy ReconnectPolicy
+	// OnReconnectGiveUp ReconnectPolicy 放弃重连时调用, 参数为已重连次数
+	OnReconnectGiveUp func(attempts int)
+
+	// TLS 使用 TLS 连接 (manager.conf tlsenable, 默认端口 5039)
+	TLS bool
+	// TLSCAFile 校验服务端证书的 CA 文件, 为空使用系统 CA
+	TLSCAFile string
+	// TLSCertFile/TLSKeyFile 客户端证书
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSServerName 校验证书的主机名, 为空使用 Host
+	TLSServerName         string
+	TLSInsecureSkipVerify bool
+
+	// MaxFrameSize 单条 AMI 消息最大长度, 默认 parse.DefaultMaxFrameSize
+	MaxFrameSize int
+
+	// Recorder 记录所有收发的 AMI 帧, 用于 Replay 复现
+	Recorder *record.Recorder
 
 	LogLevel logrus.Level
 	Report   bool
 }
 
+func (s *Settings) authType() AuthType {
+	if s.AuthType != AuthDefault {
+		return s.AuthType
+	}
+	if s.TLS {
+		return AuthPlain
+	}
+	return AuthMD5
+}
+
 // New creates new Amigo struct with credentials provided and returns pointer to it
 // Usage: New(username string, secret string, [host string, [port string]])
 // 建立连接
@@ -57,23 +119,42 @@ func New(settings *Settings, Log *logrus.Entry) *Amigo {
 	}
 
 	amiInstance := &Amigo{
-		settings:     settings,
-		eventEmitter: eventEmitter,
-		mutex:        &sync.RWMutex{},
-		connected:    false,
+		settings:      settings,
+		eventEmitter:  eventEmitter,
+		subscriptions: newSubscriptions(),
+		mutex:         &sync.RWMutex{},
+		connected:     false,
+		closed:        make(chan struct{}),
+		msg:           make(chan string, 1024),
 	}
 
+	// 各次连接共用一个消息处理 goroutine, 事件按收到顺序分发
+	amiInstance.spawn(amiInstance.handleMsg)
+
 	amiInstance.ConnectOn(func(payload ...interface{}) {
 		status := payload[0].(pkg.ConnectStatus)
-		if amiInstance.ami.reconnect && status != pkg.Connect_OK {
-			<-time.After(utils.ReconnectInterval)
-			utils.Log.Errorf("reconnect and reinit ami")
-			amiInstance.initAMI()
+		switch status {
+		case pkg.Connect_OK:
+			amiInstance.mutex.Lock()
+			amiInstance.attempts = 0
+			amiInstance.loggedIn = true
+			amiInstance.mutex.Unlock()
+			amiInstance.loginHooks.notify(nil)
+		case pkg.Connect_Network_Error, pkg.Disconnect_Network_Error:
+			amiInstance.mutex.Lock()
+			amiInstance.loggedIn = false
+			reconnect := amiInstance.ami.reconnect
+			amiInstance.mutex.Unlock()
+			if reconnect {
+				// 在独立 goroutine 中等待, 避免占用 Emit
+				amiInstance.spawn(amiInstance.reconnect)
+			}
 		}
 	})
 
 	eventEmitter.On("namiEvent", func(payload ...interface{}) {
 		event := payload[0].(*parse.Event)
+		amiInstance.subscriptions.dispatch(event)
 		eventEmitter.Emit("AMI_Event", event.Data)
 	})
 
@@ -83,73 +164,154 @@ func New(settings *Settings, Log *logrus.Entry) *Amigo {
 // Send used to execute Actions in Asterisk. Returns immediately response from asterisk. Full response will follow.
 // Usage amigo.Send(action map[string]string)
 func (a *Amigo) Send(action map[string]string) (data map[string]string, event []parse.Event, err error) {
-	utils.Log.Debugf("send action: %+v\n", action)
-	if !a.Connected() {
-		utils.Log.Warnf("ami not connected")
-		return nil, nil, utils.ErrNotConnected
+	ctx, cancel := context.WithTimeout(context.Background(), utils.ActionTimeout*time.Second)
+	defer cancel()
+
+	data, event, err = a.SendContext(ctx, action)
+	if err == context.DeadlineExceeded {
+		utils.Log.Warnf("action %+v wait complete chan failure ActionTimeout: %d", action, utils.ActionTimeout)
 	}
+	return data, event, err
+}
 
-	actionID := utils.NewV4()
-	action["ActionID"] = actionID
-	a.responses.Store(actionID, parse.NewResponse(""))
-	a.ami.exec(action)
+// SendContext 与 Send 相同, 但等待响应时遵循 ctx 的取消和超时
+// ctx 结束时移除等待中的 action 并返回 ctx.Err()
+func (a *Amigo) SendContext(ctx context.Context, action map[string]string) (data map[string]string, event []parse.Event, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 
-	done := make(chan struct{}, 1)
+	return a.send(ctx, ActionFromMap(action)).WaitContext(ctx)
+}
 
-	// 响应处理(1.超时 2.连接断开)
-	go func() {
-		select {
-		case <-a.ami.chanStop:
-			if res, ok := a.responses.Load(actionID); ok {
-				utils.Log.Warnf("action %+v %s wait complete chan failure CHAN-STOP", action, actionID)
-				res.(*parse.Response).Complete <- struct{}{}
-				return
-			}
-		case <-time.After(utils.ActionTimeout * time.Second):
-			if res, ok := a.responses.Load(actionID); ok {
-				utils.Log.Warnf("action %+v %s wait complete chan failure ActionTimeout: %d", action, actionID, utils.ActionTimeout)
-				res.(*parse.Response).Complete <- struct{}{}
-				return
-			}
-		case <-done:
-			return
-		}
-	}()
+// SendAsync 发送 action 后立即返回 Future, 不阻塞调用方
+// 超过 utils.ActionTimeout 未完成时以 utils.ErrActionTimeout 结束
+func (a *Amigo) SendAsync(action map[string]string) *Future {
+	future := a.send(context.Background(), ActionFromMap(action))
+	select {
+	case <-future.Done():
+	default:
+		future.expire(utils.ActionTimeout * time.Second)
+	}
+	return future
+}
 
-	resInterface, _ := a.responses.Load(actionID)
-	res := resInterface.(*parse.Response)
-	<-res.Complete
-	done <- struct{}{}
+// send 登记等待响应的 action 并交给 writer
+func (a *Amigo) send(ctx context.Context, action *Action) *Future {
+	utils.Log.Debugf("send action: %q\n", action.masked().String())
+	action = action.clone()
+	actionID := action.Get("ActionID")
+	if actionID == "" {
+		actionID = utils.NewV4()
+		action.Set("ActionID", actionID)
+	}
+	future := newFuture(a, actionID, action.Name())
+	if err := action.Validate(); err != nil {
+		future.resolve(err)
+		return future
+	}
+	if a.isClosed() {
+		future.resolve(utils.ErrClosed)
+		return future
+	}
+	if !a.Connected() {
+		utils.Log.Warnf("ami not connected")
+		future.resolve(utils.ErrNotConnected)
+		return future
+	}
 
-	close(res.Complete)
-	a.responses.Delete(actionID)
+	a.mutex.RLock()
+	ami := a.ami
+	a.mutex.RUnlock()
 
-	res.RLock()
-	if res.Data["Action"] == "logoff" {
-		a.ami.reconnect = false
+	a.responses.Store(actionID, future)
+	if err := ami.exec(ctx, action); err != nil {
+		future.resolve(err)
 	}
-	//utils.Log.Infof("len data: %+v\n %+v\n %+v \n %+v\n", res, res.Data, res.Message, res.Events)
-	dataLen := len(res.Data)
-	res.RUnlock()
+	return future
+}
 
-	if dataLen == 0 {
-		return nil, nil, errors.New("wait complete response failure, actionID: " + actionID)
-	}
-	return res.Data, res.Events, nil
+// failPending 以 err 结束全部等待中的 action
+func (a *Amigo) failPending(err error) {
+	a.responses.Range(func(key, value interface{}) bool {
+		value.(*Future).resolve(err)
+		return true
+	})
 }
 
 // Connect with Asterisk.
-// If connect fails, will try to reconnect every second.
+// If connect fails, will try to reconnect according to Settings.ReconnectPolicy.
 func (a *Amigo) Connect() {
 	a.mutex.RLock()
-	if a.connected {
+	connected := a.connected
+	a.mutex.RUnlock()
+	if connected || a.isClosed() {
 		return
 	}
-	a.mutex.RUnlock()
 
 	a.initAMI()
 }
 
+// Close 发送 Logoff 并停止重连, 以 utils.ErrClosed 结束等待中的 action,
+// 等待 reader/writer/pinger/handleMsg 等 goroutine 全部退出或 ctx 结束
+func (a *Amigo) Close(ctx context.Context) error {
+	first := false
+	a.closeOnce.Do(func() {
+		first = true
+	})
+
+	if first {
+		if a.Connected() {
+			// Asterisk 回复 Goodbye 后会立即断开, 响应可能先于断开丢失
+			if _, _, err := a.SendContext(ctx, map[string]string{"Action": "Logoff"}); err != nil && err != utils.ErrNotConnected {
+				utils.Log.Warnf("ami logoff %s", err)
+			}
+		}
+		a.closeMutex.Lock()
+		close(a.closed)
+		a.closeMutex.Unlock()
+		a.failPending(utils.ErrClosed)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// spawn 在 Close 之前启动由 Close 等待的 goroutine, 已关闭时不启动并返回 false
+// 检查 closed 和 wg.Add 与 Close 关闭 closed 持有同一把锁, Close 的 wg.Wait 不会漏掉之后 Add 的 goroutine
+func (a *Amigo) spawn(fn func()) bool {
+	a.closeMutex.Lock()
+	defer a.closeMutex.Unlock()
+	if a.isClosed() {
+		return false
+	}
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		fn()
+	}()
+	return true
+}
+
+func (a *Amigo) isClosed() bool {
+	select {
+	case <-a.closed:
+		return true
+	default:
+		return false
+	}
+}
+
 func (a *Amigo) initAMI() {
 	newAMIAdapter(a.settings, a.eventEmitter, a)
 }
@@ -161,6 +323,26 @@ func (a *Amigo) Connected() bool {
 	return a.ami != nil && a.ami.online()
 }
 
+// loggedInNow 返回当前连接是否已登录
+func (a *Amigo) loggedInNow() bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.loggedIn && a.ami != nil && a.ami.online()
+}
+
+// onLogin 每次登录成功后在独立 goroutine 中执行 fn, 供各子系统重新同步状态
+// 注册时已登录则立即执行一次, 返回的 Subscription 用于取消
+func (a *Amigo) onLogin(fn func()) Subscription {
+	run := func(interface{}) {
+		a.spawn(fn)
+	}
+	sub := a.loginHooks.add(run)
+	if a.loggedInNow() {
+		run(nil)
+	}
+	return sub
+}
+
 // EventOn 暴露内部 Event 事件
 func (a *Amigo) EventOn(fn func(...interface{})) {
 	a.eventEmitter.AddListener("AMI_Event", fn)
@@ -189,86 +371,44 @@ func (a *Amigo) onRawResponse(response *parse.Response) {
 		return
 	}
 
-	resInterface, existRes := a.responses.Load(actionID)
+	futureInterface, existRes := a.responses.Load(actionID)
 	if !existRes {
 		utils.Log.Errorf("a.responses[actionID] is nil, actionID: %s", actionID)
 		return
 	}
 
-	res := resInterface.(*parse.Response)
+	future := futureInterface.(*Future)
+	future.res.Update(response)
 	if value, ok := response.Data["Message"]; ok && !utils.IsResponse(value) {
-		res.Data = response.Data
 		return
 	}
-
-	res.Lock()
-	res.Data = response.Data
-	res.Unlock()
-	res.Complete <- struct{}{}
+	future.resolve(nil)
 }
 func (a *Amigo) onRawEvent(event *parse.Event) {
 	if actionID, existID := event.Data["ActionID"]; existID {
-		if resInterface, existRes := a.responses.Load(actionID); existRes {
-			response := resInterface.(*parse.Response)
-			response.Events = append(response.Events, *event)
-
-			if utils.EventComplete(event.Data["Event"], event.Data["EventList"]) {
-				response.Complete <- struct{}{}
+		if futureInterface, existRes := a.responses.Load(actionID); existRes {
+			future := futureInterface.(*Future)
+			future.res.Lock()
+			future.res.Events = append(future.res.Events, *event)
+			future.res.Unlock()
+
+			if future.completedBy(event) {
+				future.resolve(nil)
 			}
+			return
 		}
-		/*
-			else {
-				// Event: OriginateResponse
-				// Privilege: call,all
-				// Timestamp: 1701241602.714315
-				// ActionID: 3e69c911-79d0-4424-be76-81284156dac7
-				// Response: Success
-				// Channel: PJSIP/100-00000009
-				// Context: playcall
-				// Exten: **19
-				// Reason: 4
-				// Uniqueid: 1701241599.27
-				// CallerIDNum: <unknown>
-				// CallerIDName: <unknown>
-
-				AmigoConnID:1
-				Action:DBGet
-				Family:DND
-				Key:809
-				ActionID:ba57f33e-5a69-4ade-8f36-eb4514e9ac29
-
-				Response: Success
-				ActionID: ba57f33e-5a69-4ade-8f36-eb4514e9ac29
-				EventList: start
-				Message: Result will follow
-
-				Event: DBGetResponse
-				Family: DND
-				Key: 809
-				Val: no
-				ActionID: ba57f33e-5a69-4ade-8f36-eb4514e9ac29
-
-				Event: DBGetComplete
-				ActionID: ba57f33e-5a69-4ade-8f36-eb4514e9ac29
-				EventList: Complete
-				ListItems: 1
-
-				// 普通 action 发出后会多一个 Event 事件, 响应已拿到, 所以下面日志不需要警告
-				utils.Log.Warnf("actionID %s can't get response", actionID)
-			}
-		*/
-		return
+		// 响应已完成的 action 之后到达的事件(如异步 Originate 的 OriginateResponse)照常分发
 	}
 	a.eventEmitter.Emit("namiEvent", event)
 }
 
-func (a *Amigo) handleMsg(stop <-chan struct{}) {
+func (a *Amigo) handleMsg() {
 	for {
 		select {
-		case <-stop:
+		case <-a.closed:
 			return
-		case msg := <-a.ami.msg:
-			a.onRawMessage(msg)
+		case message := <-a.msg:
+			a.onRawMessage(message)
 		}
 	}
 }
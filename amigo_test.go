@@ -0,0 +1,52 @@
+package amigo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+)
+
+func TestConnectLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     AuthType
+		password string
+		loggedIn bool
+	}{
+		{name: "md5", auth: AuthMD5, password: amitest.DefaultSecret, loggedIn: true},
+		{name: "default is md5", auth: AuthDefault, password: amitest.DefaultSecret, loggedIn: true},
+		{name: "plain", auth: AuthPlain, password: amitest.DefaultSecret, loggedIn: true},
+		{name: "wrong password", auth: AuthMD5, password: "wrong", loggedIn: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := amitest.NewServer()
+			defer srv.Close()
+			a := New(&Settings{Host: srv.Host, Port: srv.Port, Username: srv.Username, Password: tt.password, AuthType: tt.auth}, nil)
+			defer a.Close(context.Background())
+			a.Connect()
+
+			login, ok := srv.WaitAction("Login", 2*time.Second)
+			if !ok {
+				t.Fatal("no Login received")
+			}
+			if md5 := tt.auth != AuthPlain; md5 != (login.Get("Key") != "") || md5 == (login.Get("Secret") != "") {
+				t.Errorf("Login = %+v, want md5 %v", login.Message, md5)
+			}
+
+			if tt.loggedIn {
+				if !waitUntil(a.loggedInNow) {
+					t.Fatal("not logged in")
+				}
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+			if a.loggedInNow() {
+				t.Error("logged in with wrong password")
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package amigo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// AstDB Asterisk 内置数据库 (astdb) 的读写, 如 DND、呼叫转移等
+// key 不存在时返回的 *ActionError 可用 errors.Is(err, utils.ErrDBKeyNotFound) 判断,
+// 与连接断开、超时等错误区分
+type AstDB struct {
+	amigo *Amigo
+}
+
+// NewAstDB 创建 AstDB
+func NewAstDB(a *Amigo) *AstDB {
+	return &AstDB{amigo: a}
+}
+
+// request 与 Amigo.request 相同, DBGet/DBGetTree 的 "Database entry not found" 和
+// DBDel/DBDelTree 的 "Database entry not deleted" 时设置 utils.ErrDBKeyNotFound
+func (db *AstDB) request(ctx context.Context, action *Action) ([]parse.Event, error) {
+	_, events, err := db.amigo.request(ctx, action)
+	var actionErr *ActionError
+	if errors.As(err, &actionErr) {
+		message := strings.ToLower(actionErr.Message)
+		if strings.Contains(message, "not found") || strings.Contains(message, "not deleted") {
+			actionErr.Err = utils.ErrDBKeyNotFound
+		}
+	}
+	return events, err
+}
+
+// Get 读取 family/key 的值
+func (db *AstDB) Get(ctx context.Context, family, key string) (string, error) {
+	events, err := db.request(ctx, NewAction("DBGet").Add("Family", family).Add("Key", key))
+	if err != nil {
+		return "", err
+	}
+	for i := range events {
+		if events[i].Get("Event") == "DBGetResponse" {
+			return events[i].Get("Val"), nil
+		}
+	}
+	return "", &ActionError{Action: "DBGet", Message: "no DBGetResponse", Err: utils.ErrDBKeyNotFound}
+}
+
+// Put 写入 family/key 的值, 已存在时覆盖
+func (db *AstDB) Put(ctx context.Context, family, key, value string) error {
+	_, err := db.request(ctx, NewAction("DBPut").Add("Family", family).Add("Key", key).Add("Val", value))
+	return err
+}
+
+// Del 删除 family/key, 不存在时返回 utils.ErrDBKeyNotFound
+func (db *AstDB) Del(ctx context.Context, family, key string) error {
+	_, err := db.request(ctx, NewAction("DBDel").Add("Family", family).Add("Key", key))
+	return err
+}
+
+// DelTree 删除 family 下 key 开头的全部条目, key 为空时删除整个 family, 没有条目时返回 utils.ErrDBKeyNotFound
+func (db *AstDB) DelTree(ctx context.Context, family, key string) error {
+	action := NewAction("DBDelTree").Add("Family", family)
+	if key != "" {
+		action.Add("Key", key)
+	}
+	_, err := db.request(ctx, action)
+	return err
+}
+
+// DBTree GetTree 的结果, Values 为当前层的 key, Families 为下一层
+type DBTree struct {
+	Values   map[string]string
+	Families map[string]*DBTree
+}
+
+func newDBTree() *DBTree {
+	return &DBTree{Values: make(map[string]string), Families: make(map[string]*DBTree)}
+}
+
+// Get 按 "sub/key" 形式的相对路径读取值
+func (t *DBTree) Get(path string) (string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for _, family := range parts[:len(parts)-1] {
+		if t = t.Families[family]; t == nil {
+			return "", false
+		}
+	}
+	value, ok := t.Values[parts[len(parts)-1]]
+	return value, ok
+}
+
+// Keys 返回树中全部值的相对路径, 按字典序排列
+func (t *DBTree) Keys() []string {
+	var keys []string
+	for key := range t.Values {
+		keys = append(keys, key)
+	}
+	for family, sub := range t.Families {
+		for _, key := range sub.Keys() {
+			keys = append(keys, family+"/"+key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (t *DBTree) put(path, value string) {
+	parts := strings.Split(path, "/")
+	for _, family := range parts[:len(parts)-1] {
+		sub, ok := t.Families[family]
+		if !ok {
+			sub = newDBTree()
+			t.Families[family] = sub
+		}
+		t = sub
+	}
+	t.Values[parts[len(parts)-1]] = value
+}
+
+// GetTree 读取 family 下 key 开头的全部条目 (Asterisk 16+ DBGetTree),
+// 返回以 family/key 为根的树, family 为空时返回整个数据库
+func (db *AstDB) GetTree(ctx context.Context, family, key string) (*DBTree, error) {
+	action := NewAction("DBGetTree")
+	if family != "" {
+		action.Add("Family", family)
+	}
+	if key != "" {
+		action.Add("Key", key)
+	}
+	events, err := db.request(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := "/"
+	for _, part := range []string{family, key} {
+		if part = strings.Trim(part, "/"); part != "" {
+			prefix += part + "/"
+		}
+	}
+	tree := newDBTree()
+	for i := range events {
+		if events[i].Get("Event") != "DBGetTreeResponse" {
+			continue
+		}
+		path := events[i].Get("Key")
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		// key 本身是一个值而不是 family 时没有下一层
+		if path+"/" == prefix {
+			tree.Values[path[strings.LastIndexByte(path, '/')+1:]] = events[i].Get("Val")
+			continue
+		}
+		tree.put(strings.TrimPrefix(path, prefix), events[i].Get("Val"))
+	}
+	return tree, nil
+}
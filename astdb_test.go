@@ -0,0 +1,82 @@
+package amigo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+func TestAstDBErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   string
+		message  string
+		call     func(ctx context.Context, db *AstDB) error
+		notFound bool
+	}{
+		{
+			name:    "get not found",
+			action:  "DBGet",
+			message: "Database entry not found",
+			call: func(ctx context.Context, db *AstDB) error {
+				_, err := db.Get(ctx, "DND", "1001")
+				return err
+			},
+			notFound: true,
+		},
+		{
+			name:    "del not deleted",
+			action:  "DBDel",
+			message: "Database entry not deleted",
+			call: func(ctx context.Context, db *AstDB) error {
+				return db.Del(ctx, "DND", "1001")
+			},
+			notFound: true,
+		},
+		{
+			name:    "deltree not deleted",
+			action:  "DBDelTree",
+			message: "Database entry not deleted",
+			call: func(ctx context.Context, db *AstDB) error {
+				return db.DelTree(ctx, "CF", "")
+			},
+			notFound: true,
+		},
+		{
+			name:    "put failed",
+			action:  "DBPut",
+			message: "Failed to update entry",
+			call: func(ctx context.Context, db *AstDB) error {
+				return db.Put(ctx, "DND", "1001", "YES")
+			},
+		},
+		{
+			name:    "permission denied",
+			action:  "DBGet",
+			message: "Permission denied",
+			call: func(ctx context.Context, db *AstDB) error {
+				_, err := db.Get(ctx, "DND", "1001")
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := amitest.NewServer()
+			srv.Respond(tt.action, amitest.Response("Error", "Message", tt.message))
+			a, ctx := newTestAmigo(t, srv)
+			err := tt.call(ctx, NewAstDB(a))
+			var actionErr *ActionError
+			if !errors.As(err, &actionErr) || actionErr.Message != tt.message {
+				t.Fatalf("err = %v, want *ActionError %q", err, tt.message)
+			}
+			if notFound := errors.Is(err, utils.ErrDBKeyNotFound); notFound != tt.notFound {
+				t.Errorf("errors.Is(err, ErrDBKeyNotFound) = %v, want %v", notFound, tt.notFound)
+			}
+		})
+	}
+}
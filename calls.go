@@ -0,0 +1,573 @@
+package amigo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// CallState 通话状态
+type CallState int
+
+const (
+	// CallStateDialing 已建立通道, 尚未振铃
+	CallStateDialing CallState = iota
+	// CallStateRinging 对端振铃
+	CallStateRinging
+	// CallStateAnswered 对端应答, 即 DialEnd ANSWER 或两个以上通道进入同一个桥
+	CallStateAnswered
+	// CallStateEnded 全部通道已挂断
+	CallStateEnded
+)
+
+func (s CallState) String() string {
+	switch s {
+	case CallStateDialing:
+		return "Dialing"
+	case CallStateRinging:
+		return "Ringing"
+	case CallStateAnswered:
+		return "Answered"
+	case CallStateEnded:
+		return "Ended"
+	}
+	return "Unknown"
+}
+
+// Bridge 桥的快照
+type Bridge struct {
+	Uniqueid   string
+	Type       string
+	Technology string
+	Creator    string
+	Name       string
+	// Channels 桥内通道的 Uniqueid
+	Channels []string
+}
+
+// Call 以 Linkedid 关联的一通电话
+type Call struct {
+	Linkedid string
+	State    CallState
+	// CallerIDNum/CallerIDName 发起通道(Uniqueid 等于 Linkedid)的主叫
+	CallerIDNum  string
+	CallerIDName string
+	// Channels 当前的通道, 按创建时间排序
+	Channels []Channel
+	// Bridges 当前所在桥的 Uniqueid
+	Bridges []string
+
+	Started  time.Time
+	Answered time.Time
+	Ended    time.Time
+	// HangupCause/HangupCauseText 第一个挂断的通道的原因
+	HangupCause     int
+	HangupCauseText string
+	Transfers       int
+}
+
+// Duration 返回通话时长, 未结束时计算到当前时间
+func (c *Call) Duration() time.Duration {
+	if c.Ended.IsZero() {
+		return time.Since(c.Started)
+	}
+	return c.Ended.Sub(c.Started)
+}
+
+// CallEventType 通话生命周期事件类型
+type CallEventType int
+
+const (
+	// CallStarted 第一个通道建立
+	CallStarted CallEventType = iota
+	// CallRinging 开始振铃
+	CallRinging
+	// CallAnswered 已应答
+	CallAnswered
+	// CallTransferred 发生了成功的盲转或咨询转
+	CallTransferred
+	// CallEnded 全部通道已挂断, Call.Duration() 为通话时长
+	CallEnded
+)
+
+func (t CallEventType) String() string {
+	switch t {
+	case CallStarted:
+		return "Started"
+	case CallRinging:
+		return "Ringing"
+	case CallAnswered:
+		return "Answered"
+	case CallTransferred:
+		return "Transferred"
+	case CallEnded:
+		return "Ended"
+	}
+	return "Unknown"
+}
+
+// CallEvent Calls 的生命周期通知
+type CallEvent struct {
+	Type CallEventType
+	Call Call
+	// Event 引起变更的事件, 由 BridgeList 同步产生的通知为 nil
+	Event Event
+}
+
+type trackedCall struct {
+	Call
+	channels map[string]Channel
+	bridges  map[string]bool
+}
+
+func (c *trackedCall) snapshot() Call {
+	call := c.Call
+	call.Channels = make([]Channel, 0, len(c.channels))
+	for _, ch := range c.channels {
+		call.Channels = append(call.Channels, ch.clone())
+	}
+	sort.Slice(call.Channels, func(i, j int) bool {
+		return call.Channels[i].Created.Before(call.Channels[j].Created)
+	})
+	call.Bridges = make([]string, 0, len(c.bridges))
+	for id := range c.bridges {
+		call.Bridges = append(call.Bridges, id)
+	}
+	sort.Strings(call.Bridges)
+	return call
+}
+
+type trackedBridge struct {
+	Bridge
+	members map[string]string // Uniqueid -> Linkedid
+}
+
+func (b *trackedBridge) snapshot() Bridge {
+	bridge := b.Bridge
+	bridge.Channels = make([]string, 0, len(b.members))
+	for uniqueid := range b.members {
+		bridge.Channels = append(bridge.Channels, uniqueid)
+	}
+	sort.Strings(bridge.Channels)
+	return bridge
+}
+
+func newTrackedBridge(h *BridgeHeader) *trackedBridge {
+	return &trackedBridge{
+		Bridge: Bridge{
+			Uniqueid:   h.BridgeUniqueid,
+			Type:       h.BridgeType,
+			Technology: h.BridgeTechnology,
+			Creator:    h.BridgeCreator,
+			Name:       h.BridgeName,
+		},
+		members: make(map[string]string),
+	}
+}
+
+var callEvents = []string{
+	"BridgeCreate", "BridgeEnter", "BridgeLeave", "BridgeDestroy",
+	"DialBegin", "DialEnd", "AttendedTransfer", "BlindTransfer",
+}
+
+// Calls 在 ChannelTracker 之上按 Linkedid 将通道归并为通话, 跟踪桥、拨号与转接
+// 每次登录后通过 BridgeList/BridgeInfo 重新同步桥
+type Calls struct {
+	tracker
+	channels   *ChannelTracker
+	ownTracker bool
+
+	calls   map[string]*trackedCall
+	bridges map[string]*trackedBridge
+}
+
+// NewCalls 新建 Calls 并开始跟踪, channels 为 nil 时自行创建 ChannelTracker
+func NewCalls(a *Amigo, channels *ChannelTracker) *Calls {
+	c := &Calls{
+		channels: channels,
+		calls:    make(map[string]*trackedCall),
+		bridges:  make(map[string]*trackedBridge),
+	}
+	if c.channels == nil {
+		c.channels = NewChannelTracker(a)
+		c.ownTracker = true
+	}
+	c.subs = append(c.subs, c.channels.Watch(c.onChannel))
+	for _, ch := range c.channels.Channels() {
+		c.onChannel(ChannelChange{Type: ChannelAdded, Channel: ch})
+	}
+	c.start(a, "calls", callEvents, c.handle, c.seed)
+	return c
+}
+
+// Close 停止跟踪和重新同步, 同时关闭 NewCalls 自行创建的 ChannelTracker
+func (c *Calls) Close() {
+	c.close()
+	if c.ownTracker {
+		c.channels.Close()
+	}
+}
+
+// Channels 返回使用的 ChannelTracker
+func (c *Calls) Channels() *ChannelTracker {
+	return c.channels
+}
+
+// Get 按 Linkedid 查找通话
+func (c *Calls) Get(linkedid string) (Call, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if call, ok := c.calls[linkedid]; ok {
+		return call.snapshot(), true
+	}
+	return Call{}, false
+}
+
+// Calls 返回全部进行中的通话, 按开始时间排序
+func (c *Calls) Calls() []Call {
+	c.mutex.RLock()
+	calls := make([]Call, 0, len(c.calls))
+	for _, call := range c.calls {
+		calls = append(calls, call.snapshot())
+	}
+	c.mutex.RUnlock()
+
+	sort.Slice(calls, func(i, j int) bool {
+		if !calls[i].Started.Equal(calls[j].Started) {
+			return calls[i].Started.Before(calls[j].Started)
+		}
+		return calls[i].Linkedid < calls[j].Linkedid
+	})
+	return calls
+}
+
+// Bridge 按 Uniqueid 查找桥
+func (c *Calls) Bridge(uniqueid string) (Bridge, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if bridge, ok := c.bridges[uniqueid]; ok {
+		return bridge.snapshot(), true
+	}
+	return Bridge{}, false
+}
+
+// Bridges 返回全部桥, 按 Uniqueid 排序
+func (c *Calls) Bridges() []Bridge {
+	c.mutex.RLock()
+	bridges := make([]Bridge, 0, len(c.bridges))
+	for _, bridge := range c.bridges {
+		bridges = append(bridges, bridge.snapshot())
+	}
+	c.mutex.RUnlock()
+
+	sort.Slice(bridges, func(i, j int) bool {
+		return bridges[i].Uniqueid < bridges[j].Uniqueid
+	})
+	return bridges
+}
+
+// Watch 注册通话生命周期通知, 执行方式见 ChannelTracker.Watch
+func (c *Calls) Watch(fn func(CallEvent)) Subscription {
+	return c.watchers.add(func(v interface{}) {
+		fn(v.(CallEvent))
+	})
+}
+
+func (c *Calls) notify(events []CallEvent) {
+	for _, event := range events {
+		c.watchers.notify(event)
+	}
+}
+
+// transition 推进通话状态, 状态只前进不后退
+func (c *Calls) transition(call *trackedCall, state CallState, event Event, events []CallEvent) []CallEvent {
+	if call.State >= state {
+		return events
+	}
+	call.State = state
+	switch state {
+	case CallStateRinging:
+		return append(events, CallEvent{Type: CallRinging, Call: call.snapshot(), Event: event})
+	case CallStateAnswered:
+		call.Answered = time.Now()
+		return append(events, CallEvent{Type: CallAnswered, Call: call.snapshot(), Event: event})
+	}
+	return events
+}
+
+// bridged 桥内有两个以上通道时, 其中各通话视为已应答
+func (c *Calls) bridged(bridge *trackedBridge, event Event, events []CallEvent) []CallEvent {
+	if len(bridge.members) < 2 {
+		return events
+	}
+	for _, linkedid := range bridge.members {
+		if call, ok := c.calls[linkedid]; ok {
+			events = c.transition(call, CallStateAnswered, event, events)
+		}
+	}
+	return events
+}
+
+func (c *Calls) onChannel(change ChannelChange) {
+	ch := change.Channel
+	linkedid := ch.Linkedid
+	if linkedid == "" {
+		linkedid = ch.Uniqueid
+	}
+
+	var events []CallEvent
+	if !c.lock() {
+		return
+	}
+	call, exists := c.calls[linkedid]
+	switch change.Type {
+	case ChannelAdded, ChannelUpdated:
+		if !exists {
+			call = &trackedCall{
+				Call:     Call{Linkedid: linkedid, Started: ch.Created},
+				channels: make(map[string]Channel),
+				bridges:  make(map[string]bool),
+			}
+			c.calls[linkedid] = call
+		}
+		call.channels[ch.Uniqueid] = ch
+		if ch.Uniqueid == linkedid || call.CallerIDNum == "" {
+			call.CallerIDNum = ch.CallerIDNum
+			call.CallerIDName = ch.CallerIDName
+		}
+		if ch.Created.Before(call.Started) {
+			call.Started = ch.Created
+		}
+		if !exists {
+			events = append(events, CallEvent{Type: CallStarted, Call: call.snapshot(), Event: change.Event})
+		}
+		// 重新同步时桥可能先于通道建立
+		for id, bridge := range c.bridges {
+			if _, ok := bridge.members[ch.Uniqueid]; ok {
+				bridge.members[ch.Uniqueid] = linkedid
+				call.bridges[id] = true
+				events = c.bridged(bridge, change.Event, events)
+			}
+		}
+		if ch.StateDesc == "Ringing" {
+			events = c.transition(call, CallStateRinging, change.Event, events)
+		}
+	case ChannelRemoved:
+		if !exists {
+			break
+		}
+		delete(call.channels, ch.Uniqueid)
+		if hangup, ok := change.Event.(*HangupEvent); ok && call.HangupCause == 0 && call.HangupCauseText == "" {
+			call.HangupCause = hangup.Cause
+			call.HangupCauseText = hangup.CauseTxt
+		}
+		if len(call.channels) > 0 {
+			break
+		}
+		call.State = CallStateEnded
+		call.Ended = time.Now()
+		delete(c.calls, linkedid)
+		events = append(events, CallEvent{Type: CallEnded, Call: call.snapshot(), Event: change.Event})
+	}
+	c.mutex.Unlock()
+
+	c.notify(events)
+}
+
+func (c *Calls) handle(event *parse.Event) {
+	typed, err := DecodeEvent(event)
+	if err != nil {
+		utils.Log.Errorf("calls decode %s", err)
+		return
+	}
+
+	var events []CallEvent
+	if !c.lock() {
+		return
+	}
+	switch e := typed.(type) {
+	case *BridgeCreateEvent:
+		c.touch(e.BridgeUniqueid)
+		if _, ok := c.bridges[e.BridgeUniqueid]; !ok {
+			c.bridges[e.BridgeUniqueid] = newTrackedBridge(&e.BridgeHeader)
+		}
+	case *BridgeEnterEvent:
+		c.touch(bridgeMemberKey(e.BridgeUniqueid, e.Uniqueid))
+		bridge, ok := c.bridges[e.BridgeUniqueid]
+		if !ok {
+			bridge = newTrackedBridge(&e.BridgeHeader)
+			c.bridges[e.BridgeUniqueid] = bridge
+		}
+		bridge.members[e.Uniqueid] = e.Linkedid
+		if call, ok := c.calls[e.Linkedid]; ok {
+			call.bridges[e.BridgeUniqueid] = true
+		}
+		events = c.bridged(bridge, typed, events)
+	case *BridgeLeaveEvent:
+		c.touch(bridgeMemberKey(e.BridgeUniqueid, e.Uniqueid))
+		if bridge, ok := c.bridges[e.BridgeUniqueid]; ok {
+			delete(bridge.members, e.Uniqueid)
+		}
+		c.unlinkBridge(e.Linkedid, e.BridgeUniqueid)
+	case *BridgeDestroyEvent:
+		c.touch(e.BridgeUniqueid)
+		if bridge, ok := c.bridges[e.BridgeUniqueid]; ok {
+			for _, linkedid := range bridge.members {
+				if call, ok := c.calls[linkedid]; ok {
+					delete(call.bridges, e.BridgeUniqueid)
+				}
+			}
+			delete(c.bridges, e.BridgeUniqueid)
+		}
+	case *DialBeginEvent:
+		if call, ok := c.calls[e.Linkedid]; ok {
+			events = c.transition(call, CallStateRinging, typed, events)
+		}
+	case *DialEndEvent:
+		if call, ok := c.calls[e.Linkedid]; ok && e.DialStatus == "ANSWER" {
+			events = c.transition(call, CallStateAnswered, typed, events)
+		}
+	case *AttendedTransferEvent:
+		if e.Result == "Success" {
+			events = c.transferred(typed, events, e.OrigTransfererLinkedid, e.SecondTransfererLinkedid)
+		}
+	case *BlindTransferEvent:
+		if e.Result == "Success" {
+			events = c.transferred(typed, events, e.TransfererLinkedid)
+		}
+	}
+	c.mutex.Unlock()
+
+	c.notify(events)
+}
+
+// unlinkBridge 通话在桥内已没有通道时移除关联
+func (c *Calls) unlinkBridge(linkedid, bridgeID string) {
+	call, ok := c.calls[linkedid]
+	if !ok {
+		return
+	}
+	if bridge, ok := c.bridges[bridgeID]; ok {
+		for _, member := range bridge.members {
+			if member == linkedid {
+				return
+			}
+		}
+	}
+	delete(call.bridges, bridgeID)
+}
+
+func (c *Calls) transferred(event Event, events []CallEvent, linkedids ...string) []CallEvent {
+	seen := make(map[string]bool, len(linkedids))
+	for _, linkedid := range linkedids {
+		call, ok := c.calls[linkedid]
+		if !ok || seen[linkedid] {
+			continue
+		}
+		seen[linkedid] = true
+		call.Transfers++
+		events = append(events, CallEvent{Type: CallTransferred, Call: call.snapshot(), Event: event})
+	}
+	return events
+}
+
+// seed 通过 BridgeList/BridgeInfo 重新同步桥, 通道由 ChannelTracker 同步
+// 同步期间创建或销毁的桥、进出桥的通道以事件为准, 其余以列表为准
+func (c *Calls) seed() {
+	bridges := make(map[string]*trackedBridge)
+	var events []CallEvent
+	c.resync(func(ctx context.Context) error {
+		_, items, err := c.amigo.request(ctx, NewAction("BridgeList"))
+		if err != nil {
+			return err
+		}
+		for i := range items {
+			item := &BridgeListItemEvent{}
+			if items[i].Get("Event") != "BridgeListItem" || parse.DecodeMessage(items[i].Message, item) != nil {
+				continue
+			}
+			bridge := newTrackedBridge(&item.BridgeHeader)
+			bridges[bridge.Uniqueid] = bridge
+			if err := c.bridgeInfo(ctx, bridge); err != nil {
+				if ctx.Err() != nil {
+					// 超时或已关闭, 不合并不完整的桥成员
+					return err
+				}
+				// 列表返回后桥可能已销毁
+				utils.Log.Warnf("calls BridgeInfo %s %s", bridge.Uniqueid, err)
+			}
+		}
+		return nil
+	}, func(touched map[string]bool) {
+		for id, listed := range bridges {
+			if touched[id] {
+				continue
+			}
+			bridge, ok := c.bridges[id]
+			if !ok {
+				bridge = newTrackedBridge(&BridgeHeader{BridgeUniqueid: id})
+				c.bridges[id] = bridge
+			}
+			bridge.Bridge = listed.Bridge
+			for uniqueid, linkedid := range listed.members {
+				if !touched[bridgeMemberKey(id, uniqueid)] {
+					bridge.members[uniqueid] = linkedid
+				}
+			}
+		}
+		for id, bridge := range c.bridges {
+			listed, ok := bridges[id]
+			for uniqueid := range bridge.members {
+				if ok {
+					if _, in := listed.members[uniqueid]; in {
+						continue
+					}
+				}
+				if !touched[bridgeMemberKey(id, uniqueid)] {
+					delete(bridge.members, uniqueid)
+				}
+			}
+			if !ok && !touched[id] && len(bridge.members) == 0 {
+				delete(c.bridges, id)
+			}
+		}
+
+		for _, call := range c.calls {
+			call.bridges = make(map[string]bool)
+		}
+		for id, bridge := range c.bridges {
+			for _, linkedid := range bridge.members {
+				if call, ok := c.calls[linkedid]; ok {
+					call.bridges[id] = true
+				}
+			}
+			events = c.bridged(bridge, nil, events)
+		}
+	})
+
+	c.notify(events)
+}
+
+// bridgeMemberKey 重新同步时登记通道进出桥的 key
+func bridgeMemberKey(bridgeID, uniqueid string) string {
+	return bridgeID + "/" + uniqueid
+}
+
+// bridgeInfo 查询桥内通道, 所有桥共用重新同步的 ctx
+func (c *Calls) bridgeInfo(ctx context.Context, bridge *trackedBridge) error {
+	_, members, err := c.amigo.request(ctx, NewAction("BridgeInfo").Add("BridgeUniqueid", bridge.Uniqueid))
+	if err != nil {
+		return err
+	}
+	for i := range members {
+		member := &BridgeInfoChannelEvent{}
+		if members[i].Get("Event") != "BridgeInfoChannel" || parse.DecodeMessage(members[i].Message, member) != nil {
+			continue
+		}
+		bridge.members[member.Uniqueid] = member.Linkedid
+	}
+	return nil
+}
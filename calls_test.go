@@ -0,0 +1,193 @@
+package amigo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+)
+
+// channelEvent 构建带通道快照的事件
+func channelEvent(name, uniqueid, linkedid string, kv ...string) amitest.Message {
+	return amitest.Event(name, append([]string{
+		"Channel", "PJSIP/" + uniqueid,
+		"CallerIDNum", "1001",
+		"Uniqueid", uniqueid,
+		"Linkedid", linkedid,
+	}, kv...)...)
+}
+
+// newEventCalls 返回只由注入事件驱动的 Calls: CoreShowChannels 和 BridgeList 不回复,
+// 重新同步期间的事件以事件为准, 测试结束时 Close 取消同步
+func newEventCalls(t *testing.T) (*amitest.Server, *Calls, chan CallEvent) {
+	t.Helper()
+	srv := amitest.NewServer()
+	srv.Drop("CoreShowChannels")
+	srv.Drop("BridgeList")
+	a, _ := newTestAmigo(t, srv)
+	calls := NewCalls(a, nil)
+	t.Cleanup(calls.Close)
+	for _, action := range []string{"CoreShowChannels", "BridgeList"} {
+		if _, ok := srv.WaitAction(action, 2*time.Second); !ok {
+			t.Fatalf("no %s received", action)
+		}
+	}
+
+	events := make(chan CallEvent, 32)
+	calls.Watch(func(event CallEvent) { events <- event })
+	return srv, calls, events
+}
+
+// waitCallEvents 按顺序读取 n 个通知的类型和 Linkedid
+func waitCallEvents(t *testing.T, events chan CallEvent, n int) []string {
+	t.Helper()
+	got := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		select {
+		case event := <-events:
+			got = append(got, event.Type.String()+" "+event.Call.Linkedid)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("call events = %v, want %d", got, n)
+		}
+	}
+	return got
+}
+
+func TestCallsSeed(t *testing.T) {
+	srv := amitest.NewServer()
+	srv.RespondList("CoreShowChannels", []amitest.Message{
+		channelEvent("CoreShowChannel", "1.1", "1.1", "Duration", "00:01:00"),
+		channelEvent("CoreShowChannel", "1.2", "1.1", "Duration", "00:00:50"),
+	}, amitest.Event("CoreShowChannelsComplete"))
+	srv.RespondList("BridgeList", []amitest.Message{
+		amitest.Event("BridgeListItem", "BridgeUniqueid", "b1", "BridgeType", "basic"),
+	}, amitest.Event("BridgeListComplete"))
+	srv.RespondList("BridgeInfo", []amitest.Message{
+		channelEvent("BridgeInfoChannel", "1.1", "1.1"),
+		channelEvent("BridgeInfoChannel", "1.2", "1.1"),
+	}, amitest.Event("BridgeInfoComplete", "BridgeUniqueid", "b1"))
+	a, _ := newTestAmigo(t, srv)
+	calls := NewCalls(a, nil)
+	defer calls.Close()
+
+	if !waitUntil(func() bool {
+		call, ok := calls.Get("1.1")
+		return ok && len(call.Channels) == 2 && len(call.Bridges) == 1
+	}) {
+		t.Fatalf("Calls() = %+v, Bridges() = %+v", calls.Calls(), calls.Bridges())
+	}
+	call, _ := calls.Get("1.1")
+	if call.State != CallStateAnswered || call.Bridges[0] != "b1" || call.Channels[0].Uniqueid != "1.1" {
+		t.Errorf("Get(1.1) = %+v", call)
+	}
+	if bridge, ok := calls.Bridge("b1"); !ok || !reflect.DeepEqual(bridge.Channels, []string{"1.1", "1.2"}) {
+		t.Errorf("Bridge(b1) = %+v, %v", bridge, ok)
+	}
+	if n := len(calls.Calls()); n != 1 {
+		t.Errorf("len(Calls()) = %d, want 1", n)
+	}
+}
+
+func TestCallsLinkedid(t *testing.T) {
+	srv, calls, events := newEventCalls(t)
+
+	srv.Inject(channelEvent("Newchannel", "1.1", "1.1", "ChannelStateDesc", "Ring"))
+	srv.Inject(channelEvent("Newchannel", "1.2", "1.1", "CallerIDNum", "1002"))
+	srv.Inject(channelEvent("DialBegin", "1.1", "1.1", "DestUniqueid", "1.2", "DestLinkedid", "1.1"))
+	srv.Inject(channelEvent("Newchannel", "2.1", "2.1"))
+	srv.Inject(amitest.Event("BridgeCreate", "BridgeUniqueid", "b1"))
+	srv.Inject(channelEvent("BridgeEnter", "1.1", "1.1", "BridgeUniqueid", "b1"))
+	srv.Inject(channelEvent("BridgeEnter", "1.2", "1.1", "BridgeUniqueid", "b1"))
+
+	want := []string{"Started 1.1", "Ringing 1.1", "Started 2.1", "Answered 1.1"}
+	if got := waitCallEvents(t, events, len(want)); !reflect.DeepEqual(got, want) {
+		t.Fatalf("call events = %v, want %v", got, want)
+	}
+	call, ok := calls.Get("1.1")
+	if !ok || len(call.Channels) != 2 || call.CallerIDNum != "1001" || !reflect.DeepEqual(call.Bridges, []string{"b1"}) {
+		t.Errorf("Get(1.1) = %+v", call)
+	}
+
+	srv.Inject(channelEvent("BridgeLeave", "1.2", "1.1", "BridgeUniqueid", "b1"))
+	srv.Inject(channelEvent("Hangup", "1.2", "1.1", "Cause", "16", "Cause-txt", "Normal Clearing"))
+	srv.Inject(channelEvent("Hangup", "1.1", "1.1", "Cause", "17", "Cause-txt", "User busy"))
+	select {
+	case event := <-events:
+		if event.Type != CallEnded || event.Call.HangupCause != 16 || event.Call.HangupCauseText != "Normal Clearing" {
+			t.Errorf("call event = %v %+v", event.Type, event.Call)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("call not ended")
+	}
+	if _, ok := calls.Get("1.1"); ok {
+		t.Error("ended call still tracked")
+	}
+	if calls := calls.Calls(); len(calls) != 1 || calls[0].Linkedid != "2.1" {
+		t.Errorf("Calls() = %+v", calls)
+	}
+}
+
+func TestCallsTransfer(t *testing.T) {
+	tests := []struct {
+		name      string
+		transfer  amitest.Message
+		want      []string
+		transfers map[string]int
+	}{
+		{
+			name: "blind",
+			transfer: amitest.Event("BlindTransfer", "Result", "Success",
+				"TransfererUniqueid", "1.2", "TransfererLinkedid", "1.1",
+				"TransfereeUniqueid", "1.1", "TransfereeLinkedid", "1.1",
+				"Context", "ext-local", "Extension", "1003"),
+			want:      []string{"Transferred 1.1"},
+			transfers: map[string]int{"1.1": 1, "2.1": 0},
+		},
+		{
+			name: "attended",
+			transfer: amitest.Event("AttendedTransfer", "Result", "Success",
+				"OrigTransfererUniqueid", "1.2", "OrigTransfererLinkedid", "1.1",
+				"SecondTransfererUniqueid", "2.1", "SecondTransfererLinkedid", "2.1",
+				"DestType", "Bridge"),
+			want:      []string{"Transferred 1.1", "Transferred 2.1"},
+			transfers: map[string]int{"1.1": 1, "2.1": 1},
+		},
+		{
+			name: "attended within one call",
+			transfer: amitest.Event("AttendedTransfer", "Result", "Success",
+				"OrigTransfererLinkedid", "1.1", "SecondTransfererLinkedid", "1.1"),
+			want:      []string{"Transferred 1.1"},
+			transfers: map[string]int{"1.1": 1, "2.1": 0},
+		},
+		{
+			name: "failed",
+			transfer: amitest.Event("BlindTransfer", "Result", "Fail",
+				"TransfererLinkedid", "1.1"),
+			transfers: map[string]int{"1.1": 0, "2.1": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, calls, events := newEventCalls(t)
+			srv.Inject(channelEvent("Newchannel", "1.1", "1.1"))
+			srv.Inject(channelEvent("Newchannel", "1.2", "1.1"))
+			srv.Inject(channelEvent("Newchannel", "2.1", "2.1"))
+			waitCallEvents(t, events, 2)
+
+			srv.Inject(tt.transfer)
+			// 以之后的 Newchannel 标记转接事件已处理
+			srv.Inject(channelEvent("Newchannel", "3.1", "3.1"))
+			want := append(tt.want, "Started 3.1")
+			if got := waitCallEvents(t, events, len(want)); !reflect.DeepEqual(got, want) {
+				t.Fatalf("call events = %v, want %v", got, want)
+			}
+			for linkedid, n := range tt.transfers {
+				if call, _ := calls.Get(linkedid); call.Transfers != n {
+					t.Errorf("Get(%s).Transfers = %d, want %d", linkedid, call.Transfers, n)
+				}
+			}
+		})
+	}
+}
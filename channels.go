@@ -0,0 +1,345 @@
+package amigo
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// Channel 活动通道的快照
+type Channel struct {
+	Uniqueid          string
+	Linkedid          string
+	Name              string
+	State             int
+	StateDesc         string
+	CallerIDNum       string
+	CallerIDName      string
+	ConnectedLineNum  string
+	ConnectedLineName string
+	AccountCode       string
+	Language          string
+	Context           string
+	Exten             string
+	Priority          int
+	Application       string
+	AppData           string
+	// Variables VarSet 和 ChanVariable 收集到的通道变量
+	Variables map[string]string
+	Created   time.Time
+	Updated   time.Time
+}
+
+func (c *Channel) clone() Channel {
+	copied := *c
+	copied.Variables = make(map[string]string, len(c.Variables))
+	for key, value := range c.Variables {
+		copied.Variables[key] = value
+	}
+	return copied
+}
+
+// apply 以事件中的通道快照更新字段
+func (c *Channel) apply(h *ChannelHeader) {
+	c.Uniqueid = h.Uniqueid
+	c.Linkedid = h.Linkedid
+	c.Name = h.Channel
+	c.State = h.ChannelState
+	c.StateDesc = h.ChannelStateDesc
+	c.CallerIDNum = h.CallerIDNum
+	c.CallerIDName = h.CallerIDName
+	c.ConnectedLineNum = h.ConnectedLineNum
+	c.ConnectedLineName = h.ConnectedLineName
+	c.AccountCode = h.AccountCode
+	c.Language = h.Language
+	c.Context = h.Context
+	c.Exten = h.Exten
+	c.Priority = h.Priority
+	if c.Variables == nil {
+		c.Variables = make(map[string]string)
+	}
+	for key, value := range h.ChanVariable {
+		c.Variables[key] = value
+	}
+}
+
+// ChannelChangeType 通道变更类型
+type ChannelChangeType int
+
+const (
+	// ChannelAdded 新通道
+	ChannelAdded ChannelChangeType = iota
+	// ChannelUpdated 通道状态、主叫、拨号计划位置或变量改变
+	ChannelUpdated
+	// ChannelRemoved 通道挂断, 或重新同步时已不存在
+	ChannelRemoved
+)
+
+func (t ChannelChangeType) String() string {
+	switch t {
+	case ChannelAdded:
+		return "Added"
+	case ChannelUpdated:
+		return "Updated"
+	case ChannelRemoved:
+		return "Removed"
+	}
+	return "Unknown"
+}
+
+// ChannelChange ChannelTracker 的变更通知
+type ChannelChange struct {
+	Type    ChannelChangeType
+	Channel Channel
+	// Event 引起变更的事件, 由 CoreShowChannels 列表产生的变更为 nil
+	Event Event
+}
+
+var channelEvents = []string{
+	"Newchannel", "Newstate", "NewCallerid", "NewConnectedLine", "NewAccountCode",
+	"Newexten", "VarSet", "Rename", "Hangup",
+}
+
+// ChannelTracker 按 Uniqueid 维护活动通道表
+// 每次登录后通过 CoreShowChannels 重新同步, 之后由通道事件增量更新
+type ChannelTracker struct {
+	tracker
+	channels map[string]*Channel
+}
+
+// NewChannelTracker 新建 ChannelTracker 并开始跟踪, 已登录时立即同步
+func NewChannelTracker(a *Amigo) *ChannelTracker {
+	t := &ChannelTracker{channels: make(map[string]*Channel)}
+	t.start(a, "channel tracker", channelEvents, t.handle, t.seed)
+	return t
+}
+
+// Close 停止跟踪和重新同步, Get/Channels 仍返回最后的通道表
+func (t *ChannelTracker) Close() {
+	t.close()
+}
+
+// Get 按 Uniqueid 查找通道
+func (t *ChannelTracker) Get(uniqueid string) (Channel, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	if ch, ok := t.channels[uniqueid]; ok {
+		return ch.clone(), true
+	}
+	return Channel{}, false
+}
+
+// ByName 按通道名查找通道, 如 "PJSIP/1001-00000001"
+func (t *ChannelTracker) ByName(name string) (Channel, bool) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	for _, ch := range t.channels {
+		if ch.Name == name {
+			return ch.clone(), true
+		}
+	}
+	return Channel{}, false
+}
+
+// Channels 返回全部活动通道, 按创建时间排序
+func (t *ChannelTracker) Channels() []Channel {
+	return t.Filter(nil)
+}
+
+// Filter 返回 match 为 true 的活动通道, 按创建时间排序, match 为 nil 时返回全部
+func (t *ChannelTracker) Filter(match func(*Channel) bool) []Channel {
+	t.mutex.RLock()
+	channels := make([]Channel, 0, len(t.channels))
+	for _, ch := range t.channels {
+		if match == nil || match(ch) {
+			channels = append(channels, ch.clone())
+		}
+	}
+	t.mutex.RUnlock()
+
+	sort.Slice(channels, func(i, j int) bool {
+		if !channels[i].Created.Equal(channels[j].Created) {
+			return channels[i].Created.Before(channels[j].Created)
+		}
+		return channels[i].Uniqueid < channels[j].Uniqueid
+	})
+	return channels
+}
+
+// Len 返回活动通道数
+func (t *ChannelTracker) Len() int {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return len(t.channels)
+}
+
+// Watch 注册通道增删改通知
+// 各子系统的 Watch 回调与 EventHandler 一样在消息处理 goroutine 中同步执行, 不能在回调中等待 action 的响应
+func (t *ChannelTracker) Watch(fn func(ChannelChange)) Subscription {
+	return t.watchers.add(func(v interface{}) {
+		fn(v.(ChannelChange))
+	})
+}
+
+func (t *ChannelTracker) handle(event *parse.Event) {
+	typed, err := DecodeEvent(event)
+	if err != nil {
+		utils.Log.Errorf("channel tracker decode %s", err)
+		return
+	}
+
+	var header *ChannelHeader
+	var update func(*Channel)
+	remove := false
+	switch e := typed.(type) {
+	case *NewchannelEvent:
+		header = &e.ChannelHeader
+	case *NewstateEvent:
+		header = &e.ChannelHeader
+	case *NewCalleridEvent:
+		header = &e.ChannelHeader
+	case *NewConnectedLineEvent:
+		header = &e.ChannelHeader
+	case *NewAccountCodeEvent:
+		header = &e.ChannelHeader
+	case *NewextenEvent:
+		header = &e.ChannelHeader
+		update = func(ch *Channel) {
+			if e.Extension != "" {
+				ch.Exten = e.Extension
+			}
+			ch.Application = e.Application
+			ch.AppData = e.AppData
+		}
+	case *VarSetEvent:
+		header = &e.ChannelHeader
+		update = func(ch *Channel) {
+			ch.Variables[e.Variable] = e.Value
+		}
+	case *RenameEvent:
+		header = &e.ChannelHeader
+		update = func(ch *Channel) {
+			if e.Newname != "" {
+				ch.Name = e.Newname
+			}
+		}
+	case *HangupEvent:
+		header = &e.ChannelHeader
+		remove = true
+	default:
+		return
+	}
+	if header.Uniqueid == "" {
+		// 全局变量的 VarSet 没有通道
+		return
+	}
+
+	now := time.Now()
+	if !t.lock() {
+		return
+	}
+	t.touch(header.Uniqueid)
+	ch, exists := t.channels[header.Uniqueid]
+	if remove {
+		if !exists {
+			t.mutex.Unlock()
+			return
+		}
+		delete(t.channels, header.Uniqueid)
+	} else if !exists {
+		created := now
+		if e, ok := typed.(*NewchannelEvent); ok && !e.Timestamp.IsZero() {
+			created = e.Timestamp
+		}
+		ch = &Channel{Created: created}
+		t.channels[header.Uniqueid] = ch
+	}
+
+	ch.apply(header)
+	if update != nil {
+		update(ch)
+	}
+	ch.Updated = now
+	change := ChannelChange{Type: ChannelUpdated, Channel: ch.clone(), Event: typed}
+	t.mutex.Unlock()
+
+	switch {
+	case remove:
+		change.Type = ChannelRemoved
+	case !exists:
+		change.Type = ChannelAdded
+	}
+	t.watchers.notify(change)
+}
+
+// seed 通过 CoreShowChannels 重新同步通道表
+func (t *ChannelTracker) seed() {
+	var events []parse.Event
+	var changes []ChannelChange
+	t.resync(func(ctx context.Context) (err error) {
+		_, events, err = t.amigo.request(ctx, NewAction("CoreShowChannels"))
+		return err
+	}, func(touched map[string]bool) {
+		now := time.Now()
+		listed := make(map[string]bool, len(events))
+		for i := range events {
+			item := &CoreShowChannelEvent{}
+			if events[i].Get("Event") != "CoreShowChannel" || parse.DecodeMessage(events[i].Message, item) != nil {
+				continue
+			}
+			listed[item.Uniqueid] = true
+			if touched[item.Uniqueid] {
+				continue
+			}
+			// 断线期间错过的事件以列表为准, 已有的通道保留创建时间
+			ch, exists := t.channels[item.Uniqueid]
+			if !exists {
+				ch = &Channel{Created: now.Add(-parseHMS(item.Duration))}
+			}
+			listedCh := ch.clone()
+			listedCh.apply(&item.ChannelHeader)
+			listedCh.Application = item.Application
+			listedCh.AppData = item.ApplicationData
+			if exists && reflect.DeepEqual(listedCh, ch.clone()) {
+				continue
+			}
+			listedCh.Updated = now
+			*ch = listedCh
+			t.channels[item.Uniqueid] = ch
+			change := ChannelChange{Type: ChannelAdded, Channel: ch.clone()}
+			if exists {
+				change.Type = ChannelUpdated
+			}
+			changes = append(changes, change)
+		}
+		for uniqueid, ch := range t.channels {
+			if !listed[uniqueid] && !touched[uniqueid] {
+				delete(t.channels, uniqueid)
+				changes = append(changes, ChannelChange{Type: ChannelRemoved, Channel: ch.clone()})
+			}
+		}
+	})
+
+	for _, change := range changes {
+		t.watchers.notify(change)
+	}
+}
+
+// parseHMS 解析 CoreShowChannel 的 Duration "HH:MM:SS"
+func parseHMS(value string) time.Duration {
+	var d time.Duration
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		d = d*60 + time.Duration(n)
+	}
+	return d * time.Second
+}
@@ -0,0 +1,101 @@
+package amigo
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+)
+
+// coreShowChannel 构建 CoreShowChannels 列表中的一项
+func coreShowChannel(uniqueid string, state int, desc string) amitest.Message {
+	return amitest.Event("CoreShowChannel",
+		"Channel", "PJSIP/1001-"+uniqueid,
+		"ChannelState", strconv.Itoa(state),
+		"ChannelStateDesc", desc,
+		"Exten", "1002",
+		"Uniqueid", uniqueid,
+		"Linkedid", uniqueid,
+		"Duration", "00:00:10",
+	)
+}
+
+func TestChannelTrackerResync(t *testing.T) {
+	srv := amitest.NewServer()
+	srv.RespondList("CoreShowChannels", []amitest.Message{
+		coreShowChannel("1.1", 4, "Ring"),
+		coreShowChannel("3.1", 6, "Up"),
+	}, amitest.Event("CoreShowChannelsComplete"))
+	a, _ := newTestAmigo(t, srv)
+	tracker := NewChannelTracker(a)
+	defer tracker.Close()
+
+	if !waitUntil(func() bool { return tracker.Len() == 2 }) {
+		t.Fatalf("Channels() = %+v after seed", tracker.Channels())
+	}
+	before, _ := tracker.Get("1.1")
+	if before.State != 4 || before.StateDesc != "Ring" {
+		t.Fatalf("Get(1.1) = %+v", before)
+	}
+
+	type change struct {
+		Type     ChannelChangeType
+		Uniqueid string
+		State    string
+	}
+	changes := make(chan change, 10)
+	tracker.Watch(func(c ChannelChange) {
+		changes <- change{c.Type, c.Channel.Uniqueid, c.Channel.StateDesc}
+	})
+
+	// 断线期间 1.1 接通, 2.1 新建, 3.1 挂断
+	srv.RespondList("CoreShowChannels", []amitest.Message{
+		coreShowChannel("1.1", 6, "Up"),
+		coreShowChannel("2.1", 5, "Ringing"),
+	}, amitest.Event("CoreShowChannelsComplete"))
+	srv.CloseConnections()
+
+	want := []change{
+		{ChannelUpdated, "1.1", "Up"},
+		{ChannelAdded, "2.1", "Ringing"},
+		{ChannelRemoved, "3.1", "Up"},
+	}
+	var got []change
+	for range want {
+		select {
+		case c := <-changes:
+			got = append(got, c)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("changes = %+v, want %+v", got, want)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("changes = %+v, want %+v", got, want)
+	}
+
+	after, ok := tracker.Get("1.1")
+	if !ok || after.State != 6 || !after.Created.Equal(before.Created) {
+		t.Errorf("Get(1.1) = %+v, want state 6 created %v", after, before.Created)
+	}
+	if _, ok := tracker.Get("3.1"); ok {
+		t.Error("hung up channel still tracked")
+	}
+}
+
+func TestChannelTrackerCloseCancelsResync(t *testing.T) {
+	srv := amitest.NewServer()
+	srv.Drop("CoreShowChannels")
+	a, _ := newTestAmigo(t, srv)
+	tracker := NewChannelTracker(a)
+	if _, ok := srv.WaitAction("CoreShowChannels", 2*time.Second); !ok {
+		t.Fatal("no CoreShowChannels received")
+	}
+
+	// 没有响应的重新同步不能等到 ActionTimeout 才结束
+	tracker.Close()
+	if !waitUntil(tracker.seedMutex.TryLock) {
+		t.Fatal("resync still running after Close")
+	}
+}
@@ -0,0 +1,58 @@
+package amigo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+)
+
+// Command 执行 CLI 命令, 按行原样返回输出
+// 兼容 Asterisk 13 及以前以 "--END COMMAND--" 结束的输出和 14+ 的重复 Output 头
+// 使用: lines, err := a.Command(ctx, "core show channels")
+func (a *Amigo) Command(ctx context.Context, cli string) ([]string, error) {
+	future := a.send(ctx, NewAction("Command").Add("Command", cli))
+	data, _, err := future.WaitContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	output := commandOutput(future.Response().Lines())
+	if err := responseError("Command", data); err != nil {
+		// 14+ 执行失败时 Message 为 "Command output follows", 原因在 Output 中
+		if len(output) > 0 {
+			err.(*ActionError).Output = output
+		}
+		return nil, err
+	}
+	return output, nil
+}
+
+// commandOutput 从 Command 响应的原始行中取出 CLI 输出
+func commandOutput(lines []string) []string {
+	output := make([]string, 0, len(lines))
+	if len(lines) > 0 && lines[0] == parse.ResponseFollows {
+		// Asterisk 13: 头之后直到结束标记均为输出
+		body := lines[1:]
+		for len(body) > 0 && parse.IsCommandHeader(body[0]) {
+			body = body[1:]
+		}
+		for _, line := range body {
+			if strings.HasSuffix(line, parse.CommandEnd) {
+				if line = strings.TrimSuffix(line, parse.CommandEnd); line != "" {
+					output = append(output, line)
+				}
+				break
+			}
+			output = append(output, line)
+		}
+		return output
+	}
+
+	// Asterisk 14+: 每行输出一个 Output 头, 只去掉 "Output: " 前缀以保留缩进
+	for _, line := range lines {
+		if strings.HasPrefix(line, "Output:") {
+			output = append(output, strings.TrimPrefix(strings.TrimPrefix(line, "Output:"), " "))
+		}
+	}
+	return output
+}
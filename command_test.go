@@ -0,0 +1,80 @@
+package amigo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+)
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		handle  amitest.Handler
+		want    []string
+		wantErr *ActionError
+	}{
+		{
+			name: "asterisk 13 follows",
+			handle: func(c *amitest.Conn, a amitest.Action) {
+				c.SendRaw("Response: Follows\r\nPrivilege: Command\r\nActionID: " + a.ActionID +
+					"\r\nName: value\r\n\r\n  indented\r\nlast--END COMMAND--\r\n\r\n")
+			},
+			want: []string{"Name: value", "", "  indented", "last"},
+		},
+		{
+			name: "asterisk 14 output",
+			handle: func(c *amitest.Conn, a amitest.Action) {
+				c.Reply(a, amitest.Response("Success", "Message", "Command output follows",
+					"Output", "Name: value", "Output", "  indented"))
+			},
+			want: []string{"Name: value", "  indented"},
+		},
+		{
+			name: "asterisk 14 error keeps output",
+			handle: func(c *amitest.Conn, a amitest.Action) {
+				c.Reply(a, amitest.Response("Error", "Message", "Command output follows",
+					"Output", "No such command 'core show nothing' (type 'core show help core show nothing' for other possible commands)"))
+			},
+			wantErr: &ActionError{
+				Action:  "Command",
+				Message: "Command output follows",
+				Output:  []string{"No such command 'core show nothing' (type 'core show help core show nothing' for other possible commands)"},
+			},
+		},
+		{
+			name: "permission denied",
+			handle: func(c *amitest.Conn, a amitest.Action) {
+				c.Reply(a, amitest.Response("Error", "Message", "Permission denied"))
+			},
+			wantErr: &ActionError{Action: "Command", Message: "Permission denied"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := amitest.NewServer()
+			srv.Handle("Command", tt.handle)
+			a, ctx := newTestAmigo(t, srv)
+			got, err := a.Command(ctx, "core show nothing")
+			if tt.wantErr != nil {
+				var actionErr *ActionError
+				if !errors.As(err, &actionErr) {
+					t.Fatalf("Command() err = %v, want *ActionError", err)
+				}
+				actionErr.Err = nil
+				if !reflect.DeepEqual(actionErr, tt.wantErr) {
+					t.Errorf("Command() err = %#v, want %#v", actionErr, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Command() err = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Command() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
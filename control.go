@@ -0,0 +1,187 @@
+package amigo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// 以下 action 失败时返回 *ActionError, 通道不存在时可用 errors.Is(err, utils.ErrNoSuchChannel) 判断
+
+// Hangup 挂断通道, cause 为 Q.850 原因码如 16(正常挂断)、17(用户忙), 0 使用 Asterisk 默认值
+// Asterisk 13+ 中 channel 可以是 "/^PJSIP\/1001-.*$/" 形式的正则, 挂断全部匹配的通道
+func (a *Amigo) Hangup(ctx context.Context, channel string, cause int) error {
+	action := NewAction("Hangup").Add("Channel", channel)
+	if cause > 0 {
+		action.Add("Cause", fmt.Sprint(cause))
+	}
+	_, _, err := a.request(ctx, action)
+	return err
+}
+
+// RedirectRequest Redirect 的参数, ExtraChannel 不为空时同时转移两个通道, 如将通话双方一起转走
+type RedirectRequest struct {
+	Channel string
+	Context string
+	Exten   string
+	// Priority 为空时使用 1
+	Priority string
+
+	ExtraChannel string
+	// ExtraContext/ExtraExten/ExtraPriority 为空时与 Channel 相同
+	ExtraContext  string
+	ExtraExten    string
+	ExtraPriority string
+}
+
+// Action 生成 Redirect action
+func (r *RedirectRequest) Action() *Action {
+	priority := r.Priority
+	if priority == "" {
+		priority = "1"
+	}
+	action := NewAction("Redirect").Add("Channel", r.Channel).
+		Add("Context", r.Context).Add("Exten", r.Exten).Add("Priority", priority)
+	if r.ExtraChannel == "" {
+		return action
+	}
+
+	action.Add("ExtraChannel", r.ExtraChannel)
+	extra := []parse.Header{
+		{Key: "ExtraContext", Value: r.ExtraContext},
+		{Key: "ExtraExten", Value: r.ExtraExten},
+		{Key: "ExtraPriority", Value: r.ExtraPriority},
+	}
+	for _, header := range extra {
+		if header.Value != "" {
+			action.Add(header.Key, header.Value)
+		}
+	}
+	return action
+}
+
+// Redirect 将通道转到拨号计划的指定位置
+func (a *Amigo) Redirect(ctx context.Context, req RedirectRequest) error {
+	_, _, err := a.request(ctx, req.Action())
+	return err
+}
+
+// BlindTransfer 盲转, 将 channel 的对端转到 exten@dialplanContext, channel 随后挂断
+func (a *Amigo) BlindTransfer(ctx context.Context, channel, dialplanContext, exten string) error {
+	_, _, err := a.request(ctx, NewAction("BlindTransfer").
+		Add("Channel", channel).Add("Context", dialplanContext).Add("Exten", exten))
+	return err
+}
+
+// Atxfer 以 channel 发起咨询转, 对端被保持, channel 拨打 exten
+// dialplanContext 为空时使用 channel 的 TRANSFER_CONTEXT 或当前 context
+func (a *Amigo) Atxfer(ctx context.Context, channel, dialplanContext, exten string) error {
+	action := NewAction("Atxfer").Add("Channel", channel).Add("Exten", exten)
+	if dialplanContext != "" {
+		action.Add("Context", dialplanContext)
+	}
+	_, _, err := a.request(ctx, action)
+	return err
+}
+
+// CancelAtxfer 取消 channel 正在进行的咨询转 (Asterisk 15+)
+func (a *Amigo) CancelAtxfer(ctx context.Context, channel string) error {
+	_, _, err := a.request(ctx, NewAction("CancelAtxfer").Add("Channel", channel))
+	return err
+}
+
+// Getvar 读取通道变量或函数, 如 "CDR(userfield)", channel 为空时读取全局变量
+func (a *Amigo) Getvar(ctx context.Context, channel, variable string) (string, error) {
+	action := NewAction("Getvar").Add("Variable", variable)
+	if channel != "" {
+		action.Add("Channel", channel)
+	}
+	data, _, err := a.request(ctx, action)
+	if err != nil {
+		return "", err
+	}
+	return data["Value"], nil
+}
+
+// Setvar 设置通道变量或函数, 如 "CDR(userfield)", channel 为空时设置全局变量
+func (a *Amigo) Setvar(ctx context.Context, channel, variable, value string) error {
+	action := NewAction("Setvar").Add("Variable", variable).Add("Value", value)
+	if channel != "" {
+		action.Add("Channel", channel)
+	}
+	_, _, err := a.request(ctx, action)
+	return err
+}
+
+// PlayDTMF 向通道播放一个 DTMF 按键, duration 为 0 时使用 Asterisk 默认时长
+func (a *Amigo) PlayDTMF(ctx context.Context, channel, digit string, duration time.Duration) error {
+	action := NewAction("PlayDTMF").Add("Channel", channel).Add("Digit", digit)
+	if duration > 0 {
+		action.Add("Duration", fmt.Sprint(duration.Milliseconds()))
+	}
+	_, _, err := a.request(ctx, action)
+	return err
+}
+
+// SendText 向通道发送文本消息, 如 SIP MESSAGE
+func (a *Amigo) SendText(ctx context.Context, channel, message string) error {
+	_, _, err := a.request(ctx, NewAction("SendText").Add("Channel", channel).Add("Message", message))
+	return err
+}
+
+// AbsoluteTimeout 设置通道在 timeout 后挂断, 精度为秒, 0 取消
+func (a *Amigo) AbsoluteTimeout(ctx context.Context, channel string, timeout time.Duration) error {
+	_, _, err := a.request(ctx, NewAction("AbsoluteTimeout").
+		Add("Channel", channel).Add("Timeout", fmt.Sprint(int64(timeout/time.Second))))
+	return err
+}
+
+// MuteDirection MuteAudio 的方向
+type MuteDirection string
+
+const (
+	// MuteIn 静音通道收到的声音
+	MuteIn MuteDirection = "in"
+	// MuteOut 静音发往通道的声音
+	MuteOut MuteDirection = "out"
+	// MuteAll 双向静音
+	MuteAll MuteDirection = "all"
+)
+
+// MuteAudio 静音或取消静音通道的声音
+func (a *Amigo) MuteAudio(ctx context.Context, channel string, direction MuteDirection, mute bool) error {
+	state := "off"
+	if mute {
+		state = "on"
+	}
+	_, _, err := a.request(ctx, NewAction("MuteAudio").
+		Add("Channel", channel).Add("Direction", string(direction)).Add("State", state))
+	return err
+}
+
+// Status 查询一个通道的状态, variables 为需要一并返回的通道变量
+func (a *Amigo) Status(ctx context.Context, channel string, variables ...string) (*StatusEvent, error) {
+	action := NewAction("Status").Add("Channel", channel)
+	if len(variables) > 0 {
+		action.Add("Variables", strings.Join(variables, ","))
+	}
+	_, events, err := a.request(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		if events[i].Get("Event") != "Status" {
+			continue
+		}
+		status := &StatusEvent{}
+		if err := parse.DecodeMessage(events[i].Message, status); err != nil {
+			return nil, err
+		}
+		return status, nil
+	}
+	return nil, &ActionError{Action: "Status", Message: "No such channel", Err: utils.ErrNoSuchChannel}
+}
@@ -0,0 +1,136 @@
+package amigo
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+func TestControlErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  string
+		message string
+		call    func(ctx context.Context, a *Amigo) error
+		want    error
+	}{
+		{
+			name:    "hangup no such channel",
+			action:  "Hangup",
+			message: "No such channel",
+			call: func(ctx context.Context, a *Amigo) error {
+				return a.Hangup(ctx, "PJSIP/1001-00000001", 16)
+			},
+			want: utils.ErrNoSuchChannel,
+		},
+		{
+			name:    "redirect channel does not exist",
+			action:  "Redirect",
+			message: "Channel does not exist: PJSIP/1001-00000001",
+			call: func(ctx context.Context, a *Amigo) error {
+				return a.Redirect(ctx, RedirectRequest{Channel: "PJSIP/1001-00000001", Context: "ext-local", Exten: "1002"})
+			},
+			want: utils.ErrNoSuchChannel,
+		},
+		{
+			name:    "play dtmf channel not found",
+			action:  "PlayDTMF",
+			message: "Channel 'PJSIP/1001-00000001' not found",
+			call: func(ctx context.Context, a *Amigo) error {
+				return a.PlayDTMF(ctx, "PJSIP/1001-00000001", "1", 0)
+			},
+			want: utils.ErrNoSuchChannel,
+		},
+		{
+			name:    "atxfer channel specified does not exist",
+			action:  "Atxfer",
+			message: "Channel specified does not exist",
+			call: func(ctx context.Context, a *Amigo) error {
+				return a.Atxfer(ctx, "PJSIP/1001-00000001", "", "1003")
+			},
+			want: utils.ErrNoSuchChannel,
+		},
+		{
+			name:    "getvar permission denied",
+			action:  "Getvar",
+			message: "Permission denied",
+			call: func(ctx context.Context, a *Amigo) error {
+				_, err := a.Getvar(ctx, "PJSIP/1001-00000001", "CDR(userfield)")
+				return err
+			},
+			want: utils.ErrPermissionDenied,
+		},
+		{
+			name:    "setvar unclassified",
+			action:  "Setvar",
+			message: "Failed to set variable",
+			call: func(ctx context.Context, a *Amigo) error {
+				return a.Setvar(ctx, "", "FOO", "bar")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := amitest.NewServer()
+			srv.Respond(tt.action, amitest.Response("Error", "Message", tt.message))
+			a, ctx := newTestAmigo(t, srv)
+
+			err := tt.call(ctx, a)
+			var actionErr *ActionError
+			if !errors.As(err, &actionErr) || actionErr.Action != tt.action || actionErr.Message != tt.message {
+				t.Fatalf("err = %v, want *ActionError %s %q", err, tt.action, tt.message)
+			}
+			for _, target := range []error{utils.ErrNoSuchChannel, utils.ErrPermissionDenied} {
+				if is := errors.Is(err, target); is != (target == tt.want) {
+					t.Errorf("errors.Is(err, %v) = %v", target, is)
+				}
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		events  []amitest.Message
+		want    string
+		wantErr error
+	}{
+		{
+			name: "found",
+			events: []amitest.Message{
+				channelEvent("Status", "1.1", "1.1", "ChannelStateDesc", "Up", "Seconds", "42"),
+			},
+			want: "PJSIP/1.1",
+		},
+		{
+			// Asterisk 13 对不存在的通道回复空列表
+			name:    "empty list",
+			wantErr: utils.ErrNoSuchChannel,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := amitest.NewServer()
+			srv.RespondList("Status", tt.events, amitest.Event("StatusComplete"))
+			a, ctx := newTestAmigo(t, srv)
+
+			status, err := a.Status(ctx, "PJSIP/1.1", "CDR(userfield)")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Status() err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (status.Channel != tt.want || status.ChannelStateDesc != "Up") {
+				t.Errorf("Status() = %+v", status)
+			}
+			if action, ok := srv.WaitAction("Status", time.Second); !ok || action.Get("Variables") != "CDR(userfield)" {
+				t.Errorf("Status action = %+v", action)
+			}
+		})
+	}
+}
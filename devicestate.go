@@ -0,0 +1,513 @@
+package amigo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// DeviceState 设备状态, 取值与 DeviceStateChange 事件的 State 相同
+type DeviceState string
+
+// 与 Asterisk 的 ast_devstate2str 对应
+const (
+	DeviceUnknown     DeviceState = "UNKNOWN"
+	DeviceNotInUse    DeviceState = "NOT_INUSE"
+	DeviceInUse       DeviceState = "INUSE"
+	DeviceBusy        DeviceState = "BUSY"
+	DeviceInvalid     DeviceState = "INVALID"
+	DeviceUnavailable DeviceState = "UNAVAILABLE"
+	DeviceRinging     DeviceState = "RINGING"
+	DeviceRingInUse   DeviceState = "RINGINUSE"
+	DeviceOnHold      DeviceState = "ONHOLD"
+)
+
+// deviceStateText EndpointList 等列表中使用的可读名称
+var deviceStateText = map[string]DeviceState{
+	"unknown":     DeviceUnknown,
+	"not in use":  DeviceNotInUse,
+	"in use":      DeviceInUse,
+	"busy":        DeviceBusy,
+	"invalid":     DeviceInvalid,
+	"unavailable": DeviceUnavailable,
+	"ringing":     DeviceRinging,
+	"ring+inuse":  DeviceRingInUse,
+	"on hold":     DeviceOnHold,
+}
+
+// ParseDeviceState 解析 "NOT_INUSE" 或 "Not in use" 两种形式的设备状态
+func ParseDeviceState(value string) DeviceState {
+	value = strings.TrimSpace(value)
+	if state, ok := deviceStateText[strings.ToLower(value)]; ok {
+		return state
+	}
+	if value == "" {
+		return DeviceUnknown
+	}
+	return DeviceState(strings.ToUpper(value))
+}
+
+// Online 设备在线, 即不是 UNAVAILABLE/INVALID, UNKNOWN 视为在线
+func (s DeviceState) Online() bool {
+	return s != DeviceUnavailable && s != DeviceInvalid
+}
+
+// HintState BLF 显示的状态
+type HintState int
+
+// HintUnknown 表示分机不存在或 hint 已删除
+const (
+	HintUnknown HintState = iota
+	HintIdle
+	HintInUse
+	HintBusy
+	HintRinging
+	HintOnHold
+	HintUnavailable
+)
+
+func (s HintState) String() string {
+	switch s {
+	case HintIdle:
+		return "Idle"
+	case HintInUse:
+		return "InUse"
+	case HintBusy:
+		return "Busy"
+	case HintRinging:
+		return "Ringing"
+	case HintOnHold:
+		return "OnHold"
+	case HintUnavailable:
+		return "Unavailable"
+	}
+	return "Unknown"
+}
+
+// Hint 返回设备状态对应的 HintState
+func (s DeviceState) Hint() HintState {
+	switch s {
+	case DeviceNotInUse:
+		return HintIdle
+	case DeviceInUse:
+		return HintInUse
+	case DeviceBusy:
+		return HintBusy
+	case DeviceRinging, DeviceRingInUse:
+		return HintRinging
+	case DeviceOnHold:
+		return HintOnHold
+	case DeviceUnavailable, DeviceInvalid:
+		return HintUnavailable
+	}
+	return HintUnknown
+}
+
+// ExtensionStatus 的 Status 位
+const (
+	extensionRemoved     = -2
+	extensionDeactivated = -1
+	extensionInUse       = 1
+	extensionBusy        = 2
+	extensionUnavailable = 4
+	extensionRinging     = 8
+	extensionOnHold      = 16
+)
+
+// ExtensionHintState 将 ExtensionStatus 的 Status 位转换为 HintState
+// 同时振铃和通话时显示振铃, 便于代接
+func ExtensionHintState(status int) HintState {
+	switch {
+	case status == extensionRemoved || status == extensionDeactivated:
+		return HintUnknown
+	case status&extensionUnavailable != 0:
+		return HintUnavailable
+	case status&extensionRinging != 0:
+		return HintRinging
+	case status&extensionOnHold != 0:
+		return HintOnHold
+	case status&extensionBusy != 0:
+		return HintBusy
+	case status&extensionInUse != 0:
+		return HintInUse
+	}
+	return HintIdle
+}
+
+// ExtensionHint 分机 hint 的状态
+type ExtensionHint struct {
+	Exten      string
+	Context    string
+	Hint       string
+	Status     int
+	StatusText string
+	State      HintState
+	Updated    time.Time
+}
+
+// Target 返回 "exten@context"
+func (h *ExtensionHint) Target() string {
+	return h.Exten + "@" + h.Context
+}
+
+// Presence 在线状态
+type Presence struct {
+	Provider string
+	State    string
+	Subtype  string
+	Message  string
+	Updated  time.Time
+}
+
+// StateChange States 的变更通知
+type StateChange struct {
+	// Target 变更的对象: 分机 "1001@ext-local", 设备 "PJSIP/1001" 或 presence provider "CustomPresence:1001"
+	Target string
+	// State presence 变更时为 HintUnknown, 状态见 Presence.State
+	State HintState
+	// Extension 分机变更时有值
+	Extension *ExtensionHint
+	// Device 设备变更时有值
+	Device DeviceState
+	// Presence presence 变更时有值
+	Presence *Presence
+	// Event 引起变更的事件, 由 DeviceStateList/ExtensionStateList 同步产生的变更为 nil
+	Event Event
+}
+
+var stateEvents = []string{"DeviceStateChange", "ExtensionStatus", "PresenceStateChange"}
+
+// States 缓存设备状态、分机 hint 状态和 presence, 用于 BLF
+// 每次登录后通过 DeviceStateList/ExtensionStateList 重新同步, 之后由事件更新
+type States struct {
+	tracker
+	devices  map[string]DeviceState
+	hints    map[string]*ExtensionHint
+	presence map[string]*Presence
+	// targets 按 target 注册的通知, 接收全部变更的通知在 tracker.watchers 中
+	targets map[string]*watchers
+}
+
+// NewStates 新建 States 并开始跟踪, 已登录时立即同步
+func NewStates(a *Amigo) *States {
+	s := &States{
+		devices:  make(map[string]DeviceState),
+		hints:    make(map[string]*ExtensionHint),
+		presence: make(map[string]*Presence),
+		targets:  make(map[string]*watchers),
+	}
+	s.start(a, "states", stateEvents, s.handle, s.seed)
+	return s
+}
+
+// Close 停止跟踪和重新同步, Extension/Device/Presence 仍返回最后的状态
+func (s *States) Close() {
+	s.close()
+}
+
+// Extension 返回分机 hint 的状态, target 为 "exten@context"
+func (s *States) Extension(target string) (ExtensionHint, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if hint, ok := s.hints[target]; ok {
+		return *hint, true
+	}
+	return ExtensionHint{}, false
+}
+
+// Extensions 返回全部分机 hint, 按 "exten@context" 排序
+func (s *States) Extensions() []ExtensionHint {
+	s.mutex.RLock()
+	hints := make([]ExtensionHint, 0, len(s.hints))
+	for _, hint := range s.hints {
+		hints = append(hints, *hint)
+	}
+	s.mutex.RUnlock()
+
+	sort.Slice(hints, func(i, j int) bool {
+		return hints[i].Target() < hints[j].Target()
+	})
+	return hints
+}
+
+// Device 返回设备状态, 如 "PJSIP/1001", 未知设备返回 DeviceUnknown
+func (s *States) Device(device string) DeviceState {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if state, ok := s.devices[device]; ok {
+		return state
+	}
+	return DeviceUnknown
+}
+
+// Devices 返回全部设备状态
+func (s *States) Devices() map[string]DeviceState {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	devices := make(map[string]DeviceState, len(s.devices))
+	for device, state := range s.devices {
+		devices[device] = state
+	}
+	return devices
+}
+
+// Presence 返回 presence provider 的状态
+func (s *States) Presence(provider string) (Presence, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if presence, ok := s.presence[provider]; ok {
+		return *presence, true
+	}
+	return Presence{}, false
+}
+
+// Watch 注册 target 的变更通知, target 为 "1001@ext-local"、设备名或 presence provider, 为空时接收全部变更
+// 注册时不会回调当前状态, 执行方式见 ChannelTracker.Watch
+//
+//	sub := states.Watch("1001@ext-local", func(c amigo.StateChange) { lamp.Set(c.State) })
+//	defer sub.Unsubscribe()
+func (s *States) Watch(target string, fn func(StateChange)) Subscription {
+	watch := func(v interface{}) {
+		fn(v.(StateChange))
+	}
+	if target == "" {
+		return s.watchers.add(watch)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	list, ok := s.targets[target]
+	if !ok {
+		list = &watchers{}
+		s.targets[target] = list
+	}
+	return &targetSubscription{Subscription: list.add(watch), states: s, target: target, list: list}
+}
+
+// targetSubscription 取消后 target 没有其他通知时从 States.targets 移除
+type targetSubscription struct {
+	Subscription
+	states *States
+	target string
+	list   *watchers
+}
+
+// Unsubscribe implements Subscription
+func (t *targetSubscription) Unsubscribe() {
+	t.states.mutex.Lock()
+	defer t.states.mutex.Unlock()
+	t.Subscription.Unsubscribe()
+	if t.list.len() == 0 && t.states.targets[t.target] == t.list {
+		delete(t.states.targets, t.target)
+	}
+}
+
+func (s *States) notify(changes []StateChange) {
+	for _, change := range changes {
+		s.mutex.RLock()
+		list := s.targets[change.Target]
+		s.mutex.RUnlock()
+		if list != nil {
+			list.notify(change)
+		}
+		s.watchers.notify(change)
+	}
+}
+
+func (s *States) handle(event *parse.Event) {
+	typed, err := DecodeEvent(event)
+	if err != nil {
+		utils.Log.Errorf("states decode %s", err)
+		return
+	}
+
+	now := time.Now()
+	var change StateChange
+	if !s.lock() {
+		return
+	}
+	switch e := typed.(type) {
+	case *DeviceStateChangeEvent:
+		state := ParseDeviceState(e.State)
+		s.devices[e.Device] = state
+		change = StateChange{Target: e.Device, State: state.Hint(), Device: state}
+	case *ExtensionStatusEvent:
+		hint := &ExtensionHint{
+			Exten:      e.Exten,
+			Context:    e.Context,
+			Hint:       e.Hint,
+			Status:     e.Status,
+			StatusText: e.StatusText,
+			State:      ExtensionHintState(e.Status),
+			Updated:    now,
+		}
+		if e.Status == extensionRemoved {
+			delete(s.hints, hint.Target())
+		} else {
+			s.hints[hint.Target()] = hint
+		}
+		copied := *hint
+		change = StateChange{Target: hint.Target(), State: hint.State, Extension: &copied}
+	case *PresenceStateChangeEvent:
+		presence := &Presence{
+			Provider: e.Presentity,
+			State:    e.Status,
+			Subtype:  e.Subtype,
+			Message:  e.Message,
+			Updated:  now,
+		}
+		s.presence[e.Presentity] = presence
+		copied := *presence
+		change = StateChange{Target: e.Presentity, Presence: &copied}
+	default:
+		s.mutex.Unlock()
+		return
+	}
+	s.touch(change.Target)
+	s.mutex.Unlock()
+
+	change.Event = typed
+	s.notify([]StateChange{change})
+}
+
+// seed 通过 DeviceStateList/ExtensionStateList 重新同步, 只通知状态改变的对象
+// 同步期间有事件的设备和分机以事件为准
+func (s *States) seed() {
+	var devices map[string]DeviceState
+	var hints []ExtensionHint
+	var changes []StateChange
+	s.resync(func(ctx context.Context) (err error) {
+		if devices, err = s.amigo.DeviceStateList(ctx); err != nil {
+			return err
+		}
+		hints, err = s.amigo.ExtensionStateList(ctx)
+		return err
+	}, func(touched map[string]bool) {
+		for device, state := range devices {
+			if touched[device] {
+				continue
+			}
+			if old, ok := s.devices[device]; !ok || old != state {
+				changes = append(changes, StateChange{Target: device, State: state.Hint(), Device: state})
+			}
+			s.devices[device] = state
+		}
+		for device := range s.devices {
+			if _, ok := devices[device]; !ok && !touched[device] {
+				delete(s.devices, device)
+			}
+		}
+
+		byTarget := make(map[string]*ExtensionHint, len(hints))
+		for i := range hints {
+			hint := &hints[i]
+			byTarget[hint.Target()] = hint
+			if touched[hint.Target()] {
+				continue
+			}
+			if old, ok := s.hints[hint.Target()]; !ok || old.Status != hint.Status {
+				copied := *hint
+				changes = append(changes, StateChange{Target: hint.Target(), State: hint.State, Extension: &copied})
+			}
+			s.hints[hint.Target()] = hint
+		}
+		for target := range s.hints {
+			if _, ok := byTarget[target]; !ok && !touched[target] {
+				delete(s.hints, target)
+			}
+		}
+	})
+
+	s.notify(changes)
+}
+
+// DeviceStateList 返回全部设备状态
+func (a *Amigo) DeviceStateList(ctx context.Context) (map[string]DeviceState, error) {
+	_, events, err := a.request(ctx, NewAction("DeviceStateList"))
+	if err != nil {
+		return nil, err
+	}
+	devices := make(map[string]DeviceState, len(events))
+	for i := range events {
+		if events[i].Get("Event") == "DeviceStateChange" {
+			devices[events[i].Get("Device")] = ParseDeviceState(events[i].Get("State"))
+		}
+	}
+	return devices, nil
+}
+
+// ExtensionStateList 返回全部配置了 hint 的分机状态
+func (a *Amigo) ExtensionStateList(ctx context.Context) ([]ExtensionHint, error) {
+	_, events, err := a.request(ctx, NewAction("ExtensionStateList"))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var hints []ExtensionHint
+	err = decodeList(events, "ExtensionStatus", func(m *parse.Message) error {
+		var item ExtensionStatusEvent
+		if err := parse.DecodeMessage(m, &item); err != nil {
+			return err
+		}
+		hints = append(hints, ExtensionHint{
+			Exten:      item.Exten,
+			Context:    item.Context,
+			Hint:       item.Hint,
+			Status:     item.Status,
+			StatusText: item.StatusText,
+			State:      ExtensionHintState(item.Status),
+			Updated:    now,
+		})
+		return nil
+	})
+	return hints, err
+}
+
+// ExtensionState 查询一个分机的 hint 状态
+func (a *Amigo) ExtensionState(ctx context.Context, exten, dialplanContext string) (*ExtensionHint, error) {
+	data, _, err := a.request(ctx, NewAction("ExtensionState").Add("Exten", exten).Add("Context", dialplanContext))
+	if err != nil {
+		return nil, err
+	}
+	var item ExtensionStatusEvent
+	if err := parse.Decode(data, &item); err != nil {
+		return nil, err
+	}
+	return &ExtensionHint{
+		Exten:      exten,
+		Context:    dialplanContext,
+		Hint:       item.Hint,
+		Status:     item.Status,
+		StatusText: item.StatusText,
+		State:      ExtensionHintState(item.Status),
+		Updated:    time.Now(),
+	}, nil
+}
+
+// PresenceState 查询 presence provider 的状态, 如 "CustomPresence:1001"
+func (a *Amigo) PresenceState(ctx context.Context, provider string) (*Presence, error) {
+	future := a.send(ctx, NewAction("PresenceState").Add("Provider", provider))
+	data, _, err := future.WaitContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError("PresenceState", data); err != nil {
+		return nil, err
+	}
+	// 响应中第一个 Message 为 "Presence State", 第二个才是 presence 的消息
+	message := ""
+	if messages := future.Response().GetAll("Message"); len(messages) > 1 {
+		message = messages[1]
+	}
+	return &Presence{
+		Provider: provider,
+		State:    data["State"],
+		Subtype:  data["Subtype"],
+		Message:  message,
+		Updated:  time.Now(),
+	}, nil
+}
@@ -0,0 +1,124 @@
+package amigo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+func TestDeviceStateList(t *testing.T) {
+	tests := []struct {
+		name    string
+		handle  func(srv *amitest.Server)
+		want    map[string]DeviceState
+		wantErr error
+	}{
+		{
+			name: "list",
+			handle: func(srv *amitest.Server) {
+				srv.RespondList("DeviceStateList", []amitest.Message{
+					amitest.Event("DeviceStateChange", "Device", "PJSIP/1001", "State", "NOT_INUSE"),
+					amitest.Event("DeviceStateChange", "Device", "PJSIP/1002", "State", "RINGING"),
+					amitest.Event("DeviceStateChange", "Device", "Custom:lamp", "State", "Unavailable"),
+				}, amitest.Event("DeviceStateListComplete"))
+			},
+			want: map[string]DeviceState{
+				"PJSIP/1001":  DeviceNotInUse,
+				"PJSIP/1002":  DeviceRinging,
+				"Custom:lamp": DeviceUnavailable,
+			},
+		},
+		{
+			name: "empty",
+			handle: func(srv *amitest.Server) {
+				srv.RespondList("DeviceStateList", nil, amitest.Event("DeviceStateListComplete"))
+			},
+			want: map[string]DeviceState{},
+		},
+		{
+			name: "permission denied",
+			handle: func(srv *amitest.Server) {
+				srv.Respond("DeviceStateList", amitest.Response("Error", "Message", "Permission denied"))
+			},
+			wantErr: utils.ErrPermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := amitest.NewServer()
+			tt.handle(srv)
+			a, ctx := newTestAmigo(t, srv)
+			got, err := a.DeviceStateList(ctx)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DeviceStateList() err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DeviceStateList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatesWatchTarget(t *testing.T) {
+	srv := amitest.NewServer()
+	a, _ := newTestAmigo(t, srv)
+	states := NewStates(a)
+	defer states.Close()
+
+	first := make(chan StateChange, 4)
+	second := make(chan StateChange, 4)
+	sub1 := states.Watch("PJSIP/1001", func(change StateChange) { first <- change })
+	sub2 := states.Watch("PJSIP/1001", func(change StateChange) { second <- change })
+
+	srv.Inject(amitest.Event("DeviceStateChange", "Device", "PJSIP/1001", "State", "INUSE"))
+	for _, changes := range []chan StateChange{first, second} {
+		select {
+		case change := <-changes:
+			if change.Device != DeviceInUse {
+				t.Errorf("change = %+v", change)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no change")
+		}
+	}
+
+	sub1.Unsubscribe()
+	states.mutex.RLock()
+	_, ok := states.targets["PJSIP/1001"]
+	states.mutex.RUnlock()
+	if !ok {
+		t.Fatal("target removed while still watched")
+	}
+	sub2.Unsubscribe()
+	sub2.Unsubscribe()
+	states.mutex.RLock()
+	n := len(states.targets)
+	states.mutex.RUnlock()
+	if n != 0 {
+		t.Errorf("len(targets) = %d after Unsubscribe, want 0", n)
+	}
+}
+
+func TestPresenceState(t *testing.T) {
+	srv := amitest.NewServer()
+	srv.Respond("PresenceState", amitest.Response("Success",
+		"Message", "Presence State",
+		"State", "away",
+		"Subtype", "lunch",
+		"Message", "back at 2pm",
+	))
+	a, ctx := newTestAmigo(t, srv)
+
+	presence, err := a.PresenceState(ctx, "CustomPresence:1001")
+	if err != nil {
+		t.Fatalf("PresenceState() err = %v", err)
+	}
+	if presence.Provider != "CustomPresence:1001" || presence.State != "away" || presence.Subtype != "lunch" || presence.Message != "back at 2pm" {
+		t.Errorf("PresenceState() = %+v", presence)
+	}
+}
@@ -0,0 +1,53 @@
+package amigo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// ActionError Asterisk 对 action 回复了 Response: Error
+type ActionError struct {
+	Action  string
+	Message string
+	// Err 可识别的错误原因, 如 utils.ErrDBKeyNotFound, 用 errors.Is 判断
+	Err error
+	// Output Command 失败时 Asterisk 返回的 CLI 输出
+	Output []string
+}
+
+func (e *ActionError) Error() string {
+	if len(e.Output) > 0 {
+		return fmt.Sprintf("ami action %s failed: %s: %s", e.Action, e.Message, strings.Join(e.Output, "\n"))
+	}
+	return fmt.Sprintf("ami action %s failed: %s", e.Action, e.Message)
+}
+
+// Unwrap 返回 Err
+func (e *ActionError) Unwrap() error {
+	return e.Err
+}
+
+// responseError Response 不是 Success 时返回 *ActionError
+func responseError(action string, data map[string]string) error {
+	if data["Response"] == "Error" {
+		return &ActionError{Action: action, Message: data["Message"], Err: classifyError(data["Message"])}
+	}
+	return nil
+}
+
+// classifyError 按 Message 识别常见的错误原因, 无法识别时返回 nil
+// 各 action 的措辞不同, 如 "No such channel", "Channel 'x' not found", "Channel specified does not exist"
+func classifyError(message string) error {
+	message = strings.ToLower(message)
+	switch {
+	case strings.Contains(message, "permission denied"):
+		return utils.ErrPermissionDenied
+	case strings.Contains(message, "no such channel"),
+		strings.Contains(message, "channel") &&
+			(strings.Contains(message, "not found") || strings.Contains(message, "does not exist")):
+		return utils.ErrNoSuchChannel
+	}
+	return nil
+}
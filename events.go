@@ -0,0 +1,899 @@
+package amigo
+
+import (
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+)
+
+// Event 类型化的 AMI 事件, 由 DecodeEvent 生成
+type Event interface {
+	// EventName 返回事件名, 即 Event 头的值
+	EventName() string
+}
+
+// EventHeader 所有事件共有的字段
+type EventHeader struct {
+	Event      string    `ami:"Event"`
+	Privilege  string    `ami:"Privilege"`
+	Timestamp  time.Time `ami:"Timestamp"`
+	SystemName string    `ami:"SystemName"`
+}
+
+// EventName implements Event
+func (h EventHeader) EventName() string {
+	return h.Event
+}
+
+// ChannelHeader 通道快照字段
+type ChannelHeader struct {
+	Channel           string `ami:"Channel"`
+	ChannelState      int    `ami:"ChannelState"`
+	ChannelStateDesc  string `ami:"ChannelStateDesc"`
+	CallerIDNum       string `ami:"CallerIDNum"`
+	CallerIDName      string `ami:"CallerIDName"`
+	ConnectedLineNum  string `ami:"ConnectedLineNum"`
+	ConnectedLineName string `ami:"ConnectedLineName"`
+	Language          string `ami:"Language"`
+	AccountCode       string `ami:"AccountCode"`
+	Context           string `ami:"Context"`
+	Exten             string `ami:"Exten"`
+	Priority          int    `ami:"Priority"`
+	Uniqueid          string `ami:"Uniqueid"`
+	Linkedid          string `ami:"Linkedid"`
+	// ChanVariable manager.conf channelvars 配置的通道变量
+	ChanVariable map[string]string `ami:"ChanVariable"`
+}
+
+// DestChannelHeader 目标通道快照字段, 用于 Dial/Agent 等事件
+type DestChannelHeader struct {
+	DestChannel           string            `ami:"DestChannel"`
+	DestChannelState      int               `ami:"DestChannelState"`
+	DestChannelStateDesc  string            `ami:"DestChannelStateDesc"`
+	DestCallerIDNum       string            `ami:"DestCallerIDNum"`
+	DestCallerIDName      string            `ami:"DestCallerIDName"`
+	DestConnectedLineNum  string            `ami:"DestConnectedLineNum"`
+	DestConnectedLineName string            `ami:"DestConnectedLineName"`
+	DestLanguage          string            `ami:"DestLanguage"`
+	DestAccountCode       string            `ami:"DestAccountCode"`
+	DestContext           string            `ami:"DestContext"`
+	DestExten             string            `ami:"DestExten"`
+	DestPriority          int               `ami:"DestPriority"`
+	DestUniqueid          string            `ami:"DestUniqueid"`
+	DestLinkedid          string            `ami:"DestLinkedid"`
+	DestChanVariable      map[string]string `ami:"DestChanVariable"`
+}
+
+// BridgeHeader 桥快照字段
+type BridgeHeader struct {
+	BridgeUniqueid        string `ami:"BridgeUniqueid"`
+	BridgeType            string `ami:"BridgeType"`
+	BridgeTechnology      string `ami:"BridgeTechnology"`
+	BridgeCreator         string `ami:"BridgeCreator"`
+	BridgeName            string `ami:"BridgeName"`
+	BridgeNumChannels     int    `ami:"BridgeNumChannels"`
+	BridgeVideoSourceMode string `ami:"BridgeVideoSourceMode"`
+}
+
+// QueueMemberHeader 队列成员字段
+type QueueMemberHeader struct {
+	Queue          string    `ami:"Queue"`
+	MemberName     string    `ami:"MemberName"`
+	Interface      string    `ami:"Interface"`
+	StateInterface string    `ami:"StateInterface"`
+	Membership     string    `ami:"Membership"`
+	Penalty        int       `ami:"Penalty"`
+	CallsTaken     int       `ami:"CallsTaken"`
+	LastCall       time.Time `ami:"LastCall"`
+	LastPause      time.Time `ami:"LastPause"`
+	LoginTime      time.Time `ami:"LoginTime"`
+	InCall         bool      `ami:"InCall"`
+	Status         int       `ami:"Status"`
+	Paused         bool      `ami:"Paused"`
+	PausedReason   string    `ami:"PausedReason"`
+	Ringinuse      bool      `ami:"Ringinuse"`
+	Wrapuptime     int       `ami:"Wrapuptime"`
+}
+
+// NewchannelEvent Event: Newchannel
+type NewchannelEvent struct {
+	EventHeader
+	ChannelHeader
+}
+
+// NewstateEvent Event: Newstate
+type NewstateEvent struct {
+	EventHeader
+	ChannelHeader
+}
+
+// HangupEvent Event: Hangup
+type HangupEvent struct {
+	EventHeader
+	ChannelHeader
+	Cause    int    `ami:"Cause"`
+	CauseTxt string `ami:"Cause-txt"`
+}
+
+// DialBeginEvent Event: DialBegin
+type DialBeginEvent struct {
+	EventHeader
+	ChannelHeader
+	DestChannelHeader
+	DialString string `ami:"DialString"`
+}
+
+// DialEndEvent Event: DialEnd
+type DialEndEvent struct {
+	EventHeader
+	ChannelHeader
+	DestChannelHeader
+	DialStatus string `ami:"DialStatus"`
+	Forward    string `ami:"Forward"`
+}
+
+// BridgeCreateEvent Event: BridgeCreate
+type BridgeCreateEvent struct {
+	EventHeader
+	BridgeHeader
+}
+
+// BridgeEnterEvent Event: BridgeEnter
+type BridgeEnterEvent struct {
+	EventHeader
+	BridgeHeader
+	ChannelHeader
+	SwapUniqueid string `ami:"SwapUniqueid"`
+}
+
+// BridgeLeaveEvent Event: BridgeLeave
+type BridgeLeaveEvent struct {
+	EventHeader
+	BridgeHeader
+	ChannelHeader
+}
+
+// BridgeDestroyEvent Event: BridgeDestroy
+type BridgeDestroyEvent struct {
+	EventHeader
+	BridgeHeader
+}
+
+// BridgeListItemEvent Event: BridgeListItem, BridgeList 的列表项
+type BridgeListItemEvent struct {
+	EventHeader
+	BridgeHeader
+	ActionID string `ami:"ActionID"`
+}
+
+// BridgeInfoChannelEvent Event: BridgeInfoChannel, BridgeInfo 返回的桥内通道
+type BridgeInfoChannelEvent struct {
+	EventHeader
+	ChannelHeader
+	ActionID string `ami:"ActionID"`
+}
+
+// BridgeInfoCompleteEvent Event: BridgeInfoComplete
+type BridgeInfoCompleteEvent struct {
+	EventHeader
+	BridgeHeader
+	ActionID string `ami:"ActionID"`
+}
+
+// AttendedTransferEvent Event: AttendedTransfer
+// 各方通道快照带有 OrigTransferer/SecondTransferer/TransferTarget 等前缀, 此处只解码关联所需字段,
+// 其余头保存在 Headers
+type AttendedTransferEvent struct {
+	EventHeader
+	Result                   string            `ami:"Result"`
+	OrigTransfererChannel    string            `ami:"OrigTransfererChannel"`
+	OrigTransfererUniqueid   string            `ami:"OrigTransfererUniqueid"`
+	OrigTransfererLinkedid   string            `ami:"OrigTransfererLinkedid"`
+	OrigBridgeUniqueid       string            `ami:"OrigBridgeUniqueid"`
+	SecondTransfererChannel  string            `ami:"SecondTransfererChannel"`
+	SecondTransfererUniqueid string            `ami:"SecondTransfererUniqueid"`
+	SecondTransfererLinkedid string            `ami:"SecondTransfererLinkedid"`
+	SecondBridgeUniqueid     string            `ami:"SecondBridgeUniqueid"`
+	TransfereeChannel        string            `ami:"TransfereeChannel"`
+	TransfereeUniqueid       string            `ami:"TransfereeUniqueid"`
+	TransfereeLinkedid       string            `ami:"TransfereeLinkedid"`
+	TransferTargetChannel    string            `ami:"TransferTargetChannel"`
+	TransferTargetUniqueid   string            `ami:"TransferTargetUniqueid"`
+	TransferTargetLinkedid   string            `ami:"TransferTargetLinkedid"`
+	DestType                 string            `ami:"DestType"`
+	DestBridgeUniqueid       string            `ami:"DestBridgeUniqueid"`
+	DestApp                  string            `ami:"DestApp"`
+	IsExternal               bool              `ami:"IsExternal"`
+	Headers                  map[string]string `ami:",remain"`
+}
+
+// BlindTransferEvent Event: BlindTransfer, 其余头保存在 Headers
+type BlindTransferEvent struct {
+	EventHeader
+	Result             string            `ami:"Result"`
+	TransfererChannel  string            `ami:"TransfererChannel"`
+	TransfererUniqueid string            `ami:"TransfererUniqueid"`
+	TransfererLinkedid string            `ami:"TransfererLinkedid"`
+	TransfereeChannel  string            `ami:"TransfereeChannel"`
+	TransfereeUniqueid string            `ami:"TransfereeUniqueid"`
+	TransfereeLinkedid string            `ami:"TransfereeLinkedid"`
+	BridgeUniqueid     string            `ami:"BridgeUniqueid"`
+	IsExternal         bool              `ami:"IsExternal"`
+	Context            string            `ami:"Context"`
+	Extension          string            `ami:"Extension"`
+	Headers            map[string]string `ami:",remain"`
+}
+
+// VarSetEvent Event: VarSet
+type VarSetEvent struct {
+	EventHeader
+	ChannelHeader
+	Variable string `ami:"Variable"`
+	Value    string `ami:"Value"`
+}
+
+// NewextenEvent Event: Newexten
+type NewextenEvent struct {
+	EventHeader
+	ChannelHeader
+	Extension   string `ami:"Extension"`
+	Application string `ami:"Application"`
+	AppData     string `ami:"AppData"`
+}
+
+// NewCalleridEvent Event: NewCallerid
+type NewCalleridEvent struct {
+	EventHeader
+	ChannelHeader
+	CIDCallingPres string `ami:"CID-CallingPres"`
+}
+
+// NewConnectedLineEvent Event: NewConnectedLine
+type NewConnectedLineEvent struct {
+	EventHeader
+	ChannelHeader
+}
+
+// NewAccountCodeEvent Event: NewAccountCode
+type NewAccountCodeEvent struct {
+	EventHeader
+	ChannelHeader
+	OldAccountCode string `ami:"OldAccountCode"`
+}
+
+// RenameEvent Event: Rename, Channel 为改名前的名称
+type RenameEvent struct {
+	EventHeader
+	ChannelHeader
+	Newname string `ami:"Newname"`
+}
+
+// CoreShowChannelEvent Event: CoreShowChannel, CoreShowChannels 的列表项
+type CoreShowChannelEvent struct {
+	EventHeader
+	ChannelHeader
+	ActionID        string `ami:"ActionID"`
+	BridgeID        string `ami:"BridgeId"`
+	Application     string `ami:"Application"`
+	ApplicationData string `ami:"ApplicationData"`
+	// Duration 格式为 HH:MM:SS
+	Duration string `ami:"Duration"`
+}
+
+// StatusEvent Event: Status, Status action 返回的通道状态
+type StatusEvent struct {
+	EventHeader
+	ChannelHeader
+	ActionID      string `ami:"ActionID"`
+	Type          string `ami:"Type"`
+	DNID          string `ami:"DNID"`
+	BridgeID      string `ami:"BridgeID"`
+	Application   string `ami:"Application"`
+	Data          string `ami:"Data"`
+	Nativeformats string `ami:"Nativeformats"`
+	Readformat    string `ami:"Readformat"`
+	Writeformat   string `ami:"Writeformat"`
+	Callgroup     string `ami:"Callgroup"`
+	Pickupgroup   string `ami:"Pickupgroup"`
+	// TimeToHangup 距 AbsoluteTimeout 挂断的剩余时间, 未设置时为 0
+	TimeToHangup time.Duration `ami:"TimeToHangup"`
+	// Seconds 通道已存在的时间
+	Seconds time.Duration `ami:"Seconds"`
+	// Variable Status 的 Variables 参数请求的通道变量
+	Variable map[string]string `ami:"Variable"`
+}
+
+// DTMFBeginEvent Event: DTMFBegin
+type DTMFBeginEvent struct {
+	EventHeader
+	ChannelHeader
+	Digit     string `ami:"Digit"`
+	Direction string `ami:"Direction"`
+}
+
+// DTMFEndEvent Event: DTMFEnd
+type DTMFEndEvent struct {
+	EventHeader
+	ChannelHeader
+	Digit      string `ami:"Digit"`
+	DurationMs int    `ami:"DurationMs"`
+	Direction  string `ami:"Direction"`
+}
+
+// HoldEvent Event: Hold
+type HoldEvent struct {
+	EventHeader
+	ChannelHeader
+	MusicClass string `ami:"MusicClass"`
+}
+
+// UnholdEvent Event: Unhold
+type UnholdEvent struct {
+	EventHeader
+	ChannelHeader
+}
+
+// QueueCallerJoinEvent Event: QueueCallerJoin
+type QueueCallerJoinEvent struct {
+	EventHeader
+	ChannelHeader
+	Queue    string `ami:"Queue"`
+	Position int    `ami:"Position"`
+	Count    int    `ami:"Count"`
+}
+
+// QueueCallerLeaveEvent Event: QueueCallerLeave
+type QueueCallerLeaveEvent struct {
+	EventHeader
+	ChannelHeader
+	Queue    string `ami:"Queue"`
+	Position int    `ami:"Position"`
+	Count    int    `ami:"Count"`
+}
+
+// QueueCallerAbandonEvent Event: QueueCallerAbandon
+type QueueCallerAbandonEvent struct {
+	EventHeader
+	ChannelHeader
+	Queue            string        `ami:"Queue"`
+	Position         int           `ami:"Position"`
+	OriginalPosition int           `ami:"OriginalPosition"`
+	HoldTime         time.Duration `ami:"HoldTime"`
+}
+
+// QueueMemberAddedEvent Event: QueueMemberAdded
+type QueueMemberAddedEvent struct {
+	EventHeader
+	QueueMemberHeader
+}
+
+// QueueMemberRemovedEvent Event: QueueMemberRemoved
+type QueueMemberRemovedEvent struct {
+	EventHeader
+	QueueMemberHeader
+}
+
+// QueueMemberPauseEvent Event: QueueMemberPause
+type QueueMemberPauseEvent struct {
+	EventHeader
+	QueueMemberHeader
+}
+
+// QueueMemberStatusEvent Event: QueueMemberStatus
+type QueueMemberStatusEvent struct {
+	EventHeader
+	QueueMemberHeader
+}
+
+// QueueMemberPenaltyEvent Event: QueueMemberPenalty
+type QueueMemberPenaltyEvent struct {
+	EventHeader
+	QueueMemberHeader
+}
+
+// QueueMemberRinginuseEvent Event: QueueMemberRinginuse
+type QueueMemberRinginuseEvent struct {
+	EventHeader
+	QueueMemberHeader
+}
+
+// QueueParamsEvent Event: QueueParams, QueueStatus 返回的队列参数
+type QueueParamsEvent struct {
+	EventHeader
+	ActionID          string  `ami:"ActionID"`
+	Queue             string  `ami:"Queue"`
+	Max               int     `ami:"Max"`
+	Strategy          string  `ami:"Strategy"`
+	Calls             int     `ami:"Calls"`
+	Holdtime          int     `ami:"Holdtime"`
+	TalkTime          int     `ami:"TalkTime"`
+	Completed         int     `ami:"Completed"`
+	Abandoned         int     `ami:"Abandoned"`
+	ServiceLevel      int     `ami:"ServiceLevel"`
+	ServicelevelPerf  float64 `ami:"ServicelevelPerf"`
+	ServicelevelPerf2 float64 `ami:"ServicelevelPerf2"`
+	Weight            int     `ami:"Weight"`
+}
+
+// QueueMemberEvent Event: QueueMember, QueueStatus 返回的成员, 成员名和接口为 Name/Location
+type QueueMemberEvent struct {
+	EventHeader
+	ActionID       string    `ami:"ActionID"`
+	Queue          string    `ami:"Queue"`
+	Name           string    `ami:"Name"`
+	Location       string    `ami:"Location"`
+	StateInterface string    `ami:"StateInterface"`
+	Membership     string    `ami:"Membership"`
+	Penalty        int       `ami:"Penalty"`
+	CallsTaken     int       `ami:"CallsTaken"`
+	LastCall       time.Time `ami:"LastCall"`
+	LastPause      time.Time `ami:"LastPause"`
+	LoginTime      time.Time `ami:"LoginTime"`
+	InCall         bool      `ami:"InCall"`
+	Status         int       `ami:"Status"`
+	Paused         bool      `ami:"Paused"`
+	PausedReason   string    `ami:"PausedReason"`
+	Wrapuptime     int       `ami:"Wrapuptime"`
+}
+
+// QueueEntryEvent Event: QueueEntry, QueueStatus 返回的排队来电
+type QueueEntryEvent struct {
+	EventHeader
+	ActionID          string        `ami:"ActionID"`
+	Queue             string        `ami:"Queue"`
+	Position          int           `ami:"Position"`
+	Channel           string        `ami:"Channel"`
+	Uniqueid          string        `ami:"Uniqueid"`
+	CallerIDNum       string        `ami:"CallerIDNum"`
+	CallerIDName      string        `ami:"CallerIDName"`
+	ConnectedLineNum  string        `ami:"ConnectedLineNum"`
+	ConnectedLineName string        `ami:"ConnectedLineName"`
+	Wait              time.Duration `ami:"Wait"`
+	Priority          int           `ami:"Priority"`
+}
+
+// QueueSummaryEvent Event: QueueSummary
+type QueueSummaryEvent struct {
+	EventHeader
+	ActionID        string `ami:"ActionID"`
+	Queue           string `ami:"Queue"`
+	LoggedIn        int    `ami:"LoggedIn"`
+	Available       int    `ami:"Available"`
+	Callers         int    `ami:"Callers"`
+	HoldTime        int    `ami:"HoldTime"`
+	TalkTime        int    `ami:"TalkTime"`
+	LongestHoldTime int    `ami:"LongestHoldTime"`
+}
+
+// AgentCalledEvent Event: AgentCalled
+type AgentCalledEvent struct {
+	EventHeader
+	ChannelHeader
+	DestChannelHeader
+	Queue      string `ami:"Queue"`
+	MemberName string `ami:"MemberName"`
+	Interface  string `ami:"Interface"`
+}
+
+// AgentConnectEvent Event: AgentConnect
+type AgentConnectEvent struct {
+	EventHeader
+	ChannelHeader
+	DestChannelHeader
+	Queue      string        `ami:"Queue"`
+	MemberName string        `ami:"MemberName"`
+	Interface  string        `ami:"Interface"`
+	HoldTime   time.Duration `ami:"HoldTime"`
+	RingTime   time.Duration `ami:"RingTime"`
+}
+
+// AgentCompleteEvent Event: AgentComplete
+type AgentCompleteEvent struct {
+	EventHeader
+	ChannelHeader
+	DestChannelHeader
+	Queue      string        `ami:"Queue"`
+	MemberName string        `ami:"MemberName"`
+	Interface  string        `ami:"Interface"`
+	HoldTime   time.Duration `ami:"HoldTime"`
+	TalkTime   time.Duration `ami:"TalkTime"`
+	Reason     string        `ami:"Reason"`
+}
+
+// AgentRingNoAnswerEvent Event: AgentRingNoAnswer
+type AgentRingNoAnswerEvent struct {
+	EventHeader
+	ChannelHeader
+	DestChannelHeader
+	Queue      string        `ami:"Queue"`
+	MemberName string        `ami:"MemberName"`
+	Interface  string        `ami:"Interface"`
+	RingTime   time.Duration `ami:"RingTime"`
+}
+
+// PeerStatusEvent Event: PeerStatus
+type PeerStatusEvent struct {
+	EventHeader
+	ChannelType string `ami:"ChannelType"`
+	Peer        string `ami:"Peer"`
+	PeerStatus  string `ami:"PeerStatus"`
+	Cause       string `ami:"Cause"`
+	Address     string `ami:"Address"`
+	Port        string `ami:"Port"`
+	Time        string `ami:"Time"`
+}
+
+// ContactStatusEvent Event: ContactStatus
+type ContactStatusEvent struct {
+	EventHeader
+	URI           string        `ami:"URI"`
+	ContactStatus string        `ami:"ContactStatus"`
+	AOR           string        `ami:"AOR"`
+	EndpointName  string        `ami:"EndpointName"`
+	RoundtripUsec time.Duration `ami:"RoundtripUsec,us"`
+	UserAgent     string        `ami:"UserAgent"`
+	RegExpire     time.Time     `ami:"RegExpire"`
+	ViaAddress    string        `ami:"ViaAddress"`
+	CallID        string        `ami:"CallID"`
+}
+
+// EndpointListEvent Event: EndpointList, PJSIPShowEndpoints 的列表项
+type EndpointListEvent struct {
+	EventHeader
+	ActionID      string `ami:"ActionID"`
+	ObjectType    string `ami:"ObjectType"`
+	ObjectName    string `ami:"ObjectName"`
+	Transport     string `ami:"Transport"`
+	Aor           string `ami:"Aor"`
+	Auths         string `ami:"Auths"`
+	OutboundAuths string `ami:"OutboundAuths"`
+	// Contacts 以 ',' 分隔的 "aor/uri"
+	Contacts       string `ami:"Contacts"`
+	DeviceState    string `ami:"DeviceState"`
+	ActiveChannels string `ami:"ActiveChannels"`
+}
+
+// EndpointDetailEvent Event: EndpointDetail, 配置项较多, 未列出的保存在 Headers
+type EndpointDetailEvent struct {
+	EventHeader
+	ActionID       string            `ami:"ActionID"`
+	ObjectType     string            `ami:"ObjectType"`
+	ObjectName     string            `ami:"ObjectName"`
+	Context        string            `ami:"Context"`
+	Aors           string            `ami:"Aors"`
+	Auth           string            `ami:"Auth"`
+	OutboundAuth   string            `ami:"OutboundAuth"`
+	Transport      string            `ami:"Transport"`
+	Callerid       string            `ami:"Callerid"`
+	Allow          string            `ami:"Allow"`
+	DeviceState    string            `ami:"DeviceState"`
+	ActiveChannels string            `ami:"ActiveChannels"`
+	Headers        map[string]string `ami:",remain"`
+}
+
+// AorDetailEvent Event: AorDetail, 未列出的配置项保存在 Headers
+type AorDetailEvent struct {
+	EventHeader
+	ActionID            string            `ami:"ActionID"`
+	ObjectType          string            `ami:"ObjectType"`
+	ObjectName          string            `ami:"ObjectName"`
+	MaxContacts         int               `ami:"MaxContacts"`
+	Contacts            string            `ami:"Contacts"`
+	TotalContacts       int               `ami:"TotalContacts"`
+	ContactsRegistered  int               `ami:"ContactsRegistered"`
+	QualifyFrequency    int               `ami:"QualifyFrequency"`
+	EndpointName        string            `ami:"EndpointName"`
+	RemoveExisting      bool              `ami:"RemoveExisting"`
+	DefaultExpiration   int               `ami:"DefaultExpiration"`
+	MaximumExpiration   int               `ami:"MaximumExpiration"`
+	MinimumExpiration   int               `ami:"MinimumExpiration"`
+	AuthenticateQualify bool              `ami:"AuthenticateQualify"`
+	Headers             map[string]string `ami:",remain"`
+}
+
+// AorListEvent Event: AorList, PJSIPShowAors 的列表项
+type AorListEvent struct {
+	EventHeader
+	ActionID         string            `ami:"ActionID"`
+	ObjectType       string            `ami:"ObjectType"`
+	ObjectName       string            `ami:"ObjectName"`
+	MaxContacts      int               `ami:"MaxContacts"`
+	Contacts         string            `ami:"Contacts"`
+	QualifyFrequency int               `ami:"QualifyFrequency"`
+	RemoveExisting   bool              `ami:"RemoveExisting"`
+	Headers          map[string]string `ami:",remain"`
+}
+
+// AuthDetailEvent Event: AuthDetail, 未列出的配置项保存在 Headers
+type AuthDetailEvent struct {
+	EventHeader
+	ActionID     string            `ami:"ActionID"`
+	ObjectType   string            `ami:"ObjectType"`
+	ObjectName   string            `ami:"ObjectName"`
+	Username     string            `ami:"Username"`
+	AuthType     string            `ami:"AuthType"`
+	EndpointName string            `ami:"EndpointName"`
+	Headers      map[string]string `ami:",remain"`
+}
+
+// TransportDetailEvent Event: TransportDetail
+type TransportDetailEvent struct {
+	EventHeader
+	ActionID     string            `ami:"ActionID"`
+	ObjectType   string            `ami:"ObjectType"`
+	ObjectName   string            `ami:"ObjectName"`
+	Protocol     string            `ami:"Protocol"`
+	Bind         string            `ami:"Bind"`
+	EndpointName string            `ami:"EndpointName"`
+	Headers      map[string]string `ami:",remain"`
+}
+
+// IdentifyDetailEvent Event: IdentifyDetail
+type IdentifyDetailEvent struct {
+	EventHeader
+	ActionID     string            `ami:"ActionID"`
+	ObjectType   string            `ami:"ObjectType"`
+	ObjectName   string            `ami:"ObjectName"`
+	Endpoint     string            `ami:"Endpoint"`
+	Match        string            `ami:"Match"`
+	EndpointName string            `ami:"EndpointName"`
+	Headers      map[string]string `ami:",remain"`
+}
+
+// ContactStatusDetailEvent Event: ContactStatusDetail, PJSIPShowEndpoint 返回的联系人
+type ContactStatusDetailEvent struct {
+	EventHeader
+	ActionID      string        `ami:"ActionID"`
+	AOR           string        `ami:"AOR"`
+	URI           string        `ami:"URI"`
+	UserAgent     string        `ami:"UserAgent"`
+	RegExpire     time.Time     `ami:"RegExpire"`
+	ViaAddress    string        `ami:"ViaAddress"`
+	CallID        string        `ami:"CallID"`
+	Status        string        `ami:"Status"`
+	RoundtripUsec time.Duration `ami:"RoundtripUsec,us"`
+	EndpointName  string        `ami:"EndpointName"`
+	ID            string        `ami:"ID"`
+}
+
+// ContactListEvent Event: ContactList, PJSIPShowContacts 的列表项
+type ContactListEvent struct {
+	EventHeader
+	ActionID         string        `ami:"ActionID"`
+	ObjectType       string        `ami:"ObjectType"`
+	ObjectName       string        `ami:"ObjectName"`
+	Endpoint         string        `ami:"Endpoint"`
+	Aor              string        `ami:"Aor"`
+	URI              string        `ami:"Uri"`
+	UserAgent        string        `ami:"UserAgent"`
+	Status           string        `ami:"Status"`
+	RoundtripUsec    time.Duration `ami:"RoundtripUsec,us"`
+	ExpirationTime   time.Time     `ami:"ExpirationTime"`
+	ViaAddr          string        `ami:"ViaAddr"`
+	ViaPort          string        `ami:"ViaPort"`
+	CallID           string        `ami:"CallId"`
+	RegServer        string        `ami:"RegServer"`
+	QualifyFrequency int           `ami:"QualifyFrequency"`
+	QualifyTimeout   float64       `ami:"QualifyTimeout"`
+	OutboundProxy    string        `ami:"OutboundProxy"`
+	Path             string        `ami:"Path"`
+}
+
+// OutboundRegistrationDetailEvent Event: OutboundRegistrationDetail
+type OutboundRegistrationDetailEvent struct {
+	EventHeader
+	ActionID     string            `ami:"ActionID"`
+	ObjectType   string            `ami:"ObjectType"`
+	ObjectName   string            `ami:"ObjectName"`
+	ServerURI    string            `ami:"ServerUri"`
+	ClientURI    string            `ami:"ClientUri"`
+	Status       string            `ami:"Status"`
+	NextReg      int               `ami:"NextReg"`
+	Expiration   int               `ami:"Expiration"`
+	Transport    string            `ami:"Transport"`
+	OutboundAuth string            `ami:"OutboundAuth"`
+	ContactUser  string            `ami:"ContactUser"`
+	Endpoint     string            `ami:"Endpoint"`
+	Line         bool              `ami:"Line"`
+	Headers      map[string]string `ami:",remain"`
+}
+
+// InboundRegistrationDetailEvent Event: InboundRegistrationDetail, 即有注册联系人的 AOR
+type InboundRegistrationDetailEvent struct {
+	EventHeader
+	ActionID           string            `ami:"ActionID"`
+	ObjectType         string            `ami:"ObjectType"`
+	ObjectName         string            `ami:"ObjectName"`
+	Contacts           string            `ami:"Contacts"`
+	MaxContacts        int               `ami:"MaxContacts"`
+	TotalContacts      int               `ami:"TotalContacts"`
+	ContactsRegistered int               `ami:"ContactsRegistered"`
+	EndpointName       string            `ami:"EndpointName"`
+	Headers            map[string]string `ami:",remain"`
+}
+
+// DeviceStateChangeEvent Event: DeviceStateChange
+type DeviceStateChangeEvent struct {
+	EventHeader
+	Device string `ami:"Device"`
+	State  string `ami:"State"`
+}
+
+// ExtensionStatusEvent Event: ExtensionStatus
+type ExtensionStatusEvent struct {
+	EventHeader
+	Exten      string `ami:"Exten"`
+	Context    string `ami:"Context"`
+	Hint       string `ami:"Hint"`
+	Status     int    `ami:"Status"`
+	StatusText string `ami:"StatusText"`
+}
+
+// PresenceStateChangeEvent Event: PresenceStateChange
+type PresenceStateChangeEvent struct {
+	EventHeader
+	Presentity string `ami:"Presentity"`
+	Status     string `ami:"Status"`
+	Subtype    string `ami:"Subtype"`
+	Message    string `ami:"Message"`
+}
+
+// MixMonitorStartEvent Event: MixMonitorStart (Asterisk 14+)
+type MixMonitorStartEvent struct {
+	EventHeader
+	ChannelHeader
+}
+
+// MixMonitorStopEvent Event: MixMonitorStop (Asterisk 14+)
+type MixMonitorStopEvent struct {
+	EventHeader
+	ChannelHeader
+}
+
+// MixMonitorMuteEvent Event: MixMonitorMute (Asterisk 14+)
+type MixMonitorMuteEvent struct {
+	EventHeader
+	ChannelHeader
+	// Direction read/write/both
+	Direction string `ami:"Direction"`
+	State     bool   `ami:"State"`
+}
+
+// DBGetResponseEvent Event: DBGetResponse
+type DBGetResponseEvent struct {
+	EventHeader
+	ActionID string `ami:"ActionID"`
+	Family   string `ami:"Family"`
+	Key      string `ami:"Key"`
+	Val      string `ami:"Val"`
+}
+
+// DBGetTreeResponseEvent Event: DBGetTreeResponse, Key 为完整路径如 "/DND/1001"
+type DBGetTreeResponseEvent struct {
+	EventHeader
+	ActionID string `ami:"ActionID"`
+	Key      string `ami:"Key"`
+	Val      string `ami:"Val"`
+}
+
+// OriginateResponseEvent Event: OriginateResponse
+type OriginateResponseEvent struct {
+	EventHeader
+	ActionID     string          `ami:"ActionID"`
+	Response     string          `ami:"Response"`
+	Channel      string          `ami:"Channel"`
+	Context      string          `ami:"Context"`
+	Exten        string          `ami:"Exten"`
+	Application  string          `ami:"Application"`
+	Data         string          `ami:"Data"`
+	Reason       OriginateReason `ami:"Reason"`
+	Uniqueid     string          `ami:"Uniqueid"`
+	CallerIDNum  string          `ami:"CallerIDNum"`
+	CallerIDName string          `ami:"CallerIDName"`
+}
+
+// UserEventEvent Event: UserEvent, 其余自定义头保存在 Headers
+type UserEventEvent struct {
+	EventHeader
+	UserEvent string            `ami:"UserEvent"`
+	Headers   map[string]string `ami:",remain"`
+}
+
+// FullyBootedEvent Event: FullyBooted
+type FullyBootedEvent struct {
+	EventHeader
+	Status     string        `ami:"Status"`
+	Uptime     time.Duration `ami:"Uptime"`
+	LastReload time.Duration `ami:"LastReload"`
+}
+
+// RawEvent 未定义类型的事件, 其余字段保存在 Headers
+type RawEvent struct {
+	EventHeader
+	Headers map[string]string `ami:",remain"`
+}
+
+var eventTypes = map[string]func() Event{
+	"Newchannel":                 func() Event { return &NewchannelEvent{} },
+	"Newstate":                   func() Event { return &NewstateEvent{} },
+	"Hangup":                     func() Event { return &HangupEvent{} },
+	"DialBegin":                  func() Event { return &DialBeginEvent{} },
+	"DialEnd":                    func() Event { return &DialEndEvent{} },
+	"BridgeCreate":               func() Event { return &BridgeCreateEvent{} },
+	"BridgeEnter":                func() Event { return &BridgeEnterEvent{} },
+	"BridgeLeave":                func() Event { return &BridgeLeaveEvent{} },
+	"BridgeDestroy":              func() Event { return &BridgeDestroyEvent{} },
+	"BridgeListItem":             func() Event { return &BridgeListItemEvent{} },
+	"BridgeInfoChannel":          func() Event { return &BridgeInfoChannelEvent{} },
+	"BridgeInfoComplete":         func() Event { return &BridgeInfoCompleteEvent{} },
+	"AttendedTransfer":           func() Event { return &AttendedTransferEvent{} },
+	"BlindTransfer":              func() Event { return &BlindTransferEvent{} },
+	"VarSet":                     func() Event { return &VarSetEvent{} },
+	"Newexten":                   func() Event { return &NewextenEvent{} },
+	"DTMFBegin":                  func() Event { return &DTMFBeginEvent{} },
+	"DTMFEnd":                    func() Event { return &DTMFEndEvent{} },
+	"Hold":                       func() Event { return &HoldEvent{} },
+	"Unhold":                     func() Event { return &UnholdEvent{} },
+	"QueueCallerJoin":            func() Event { return &QueueCallerJoinEvent{} },
+	"QueueCallerLeave":           func() Event { return &QueueCallerLeaveEvent{} },
+	"QueueCallerAbandon":         func() Event { return &QueueCallerAbandonEvent{} },
+	"QueueMemberAdded":           func() Event { return &QueueMemberAddedEvent{} },
+	"QueueMemberRemoved":         func() Event { return &QueueMemberRemovedEvent{} },
+	"QueueMemberPause":           func() Event { return &QueueMemberPauseEvent{} },
+	"QueueMemberStatus":          func() Event { return &QueueMemberStatusEvent{} },
+	"QueueMemberPenalty":         func() Event { return &QueueMemberPenaltyEvent{} },
+	"QueueMemberRinginuse":       func() Event { return &QueueMemberRinginuseEvent{} },
+	"QueueParams":                func() Event { return &QueueParamsEvent{} },
+	"QueueMember":                func() Event { return &QueueMemberEvent{} },
+	"QueueEntry":                 func() Event { return &QueueEntryEvent{} },
+	"QueueSummary":               func() Event { return &QueueSummaryEvent{} },
+	"AgentCalled":                func() Event { return &AgentCalledEvent{} },
+	"AgentConnect":               func() Event { return &AgentConnectEvent{} },
+	"AgentComplete":              func() Event { return &AgentCompleteEvent{} },
+	"AgentRingNoAnswer":          func() Event { return &AgentRingNoAnswerEvent{} },
+	"PeerStatus":                 func() Event { return &PeerStatusEvent{} },
+	"ContactStatus":              func() Event { return &ContactStatusEvent{} },
+	"EndpointList":               func() Event { return &EndpointListEvent{} },
+	"EndpointDetail":             func() Event { return &EndpointDetailEvent{} },
+	"AorDetail":                  func() Event { return &AorDetailEvent{} },
+	"AorList":                    func() Event { return &AorListEvent{} },
+	"AuthDetail":                 func() Event { return &AuthDetailEvent{} },
+	"TransportDetail":            func() Event { return &TransportDetailEvent{} },
+	"IdentifyDetail":             func() Event { return &IdentifyDetailEvent{} },
+	"ContactStatusDetail":        func() Event { return &ContactStatusDetailEvent{} },
+	"ContactList":                func() Event { return &ContactListEvent{} },
+	"OutboundRegistrationDetail": func() Event { return &OutboundRegistrationDetailEvent{} },
+	"InboundRegistrationDetail":  func() Event { return &InboundRegistrationDetailEvent{} },
+	"DeviceStateChange":          func() Event { return &DeviceStateChangeEvent{} },
+	"ExtensionStatus":            func() Event { return &ExtensionStatusEvent{} },
+	"NewCallerid":                func() Event { return &NewCalleridEvent{} },
+	"NewConnectedLine":           func() Event { return &NewConnectedLineEvent{} },
+	"NewAccountCode":             func() Event { return &NewAccountCodeEvent{} },
+	"Rename":                     func() Event { return &RenameEvent{} },
+	"CoreShowChannel":            func() Event { return &CoreShowChannelEvent{} },
+	"Status":                     func() Event { return &StatusEvent{} },
+	"PresenceStateChange":        func() Event { return &PresenceStateChangeEvent{} },
+	"MixMonitorStart":            func() Event { return &MixMonitorStartEvent{} },
+	"MixMonitorStop":             func() Event { return &MixMonitorStopEvent{} },
+	"MixMonitorMute":             func() Event { return &MixMonitorMuteEvent{} },
+	"DBGetResponse":              func() Event { return &DBGetResponseEvent{} },
+	"DBGetTreeResponse":          func() Event { return &DBGetTreeResponseEvent{} },
+	"OriginateResponse":          func() Event { return &OriginateResponseEvent{} },
+	"UserEvent":                  func() Event { return &UserEventEvent{} },
+	"FullyBooted":                func() Event { return &FullyBootedEvent{} },
+}
+
+// DecodeEvent 将 parse.Event 解码为类型化事件, 未定义类型的事件返回 *RawEvent
+// 使用: switch e := event.(type) { case *amigo.HangupEvent: ... }
+func DecodeEvent(event *parse.Event) (Event, error) {
+	factory, ok := eventTypes[event.Get("Event")]
+	if !ok {
+		factory = func() Event { return &RawEvent{} }
+	}
+
+	typed := factory()
+	if err := parse.DecodeMessage(event.Message, typed); err != nil {
+		return nil, err
+	}
+	return typed, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	amigo "github.com/tqcenglish/amigo-go"
+	"github.com/tqcenglish/amigo-go/pkg"
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+)
+
+func main() {
+	srv := amitest.NewServer()
+	defer srv.Close()
+
+	srv.RespondList("SIPpeers",
+		[]amitest.Message{
+			amitest.Event("PeerEntry", "ObjectName", "701", "IPaddress", "192.168.17.77", "Status", "OK (7 ms)"),
+		},
+		amitest.Event("PeerlistComplete"),
+	)
+
+	start := make(chan bool, 1)
+	a := amigo.New(&amigo.Settings{
+		Host:     srv.Host,
+		Port:     srv.Port,
+		Username: srv.Username,
+		Password: srv.Secret,
+		LogLevel: log.WarnLevel,
+	}, nil)
+	a.ConnectOn(func(payload ...interface{}) {
+		if payload[0].(pkg.ConnectStatus) == pkg.Connect_OK {
+			start <- true
+		}
+	})
+	a.Connect()
+	<-start
+
+	res, events, err := a.SIPpeers()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("SIPpeers res %+v", res)
+	for _, event := range events {
+		log.Infof("SIPpeers event %+v", event)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := a.Close(ctx); err != nil {
+		log.Error(err)
+	}
+}
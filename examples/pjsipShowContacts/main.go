@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -45,13 +46,16 @@ func amiTest() {
 			t := time.NewTimer(next.Sub(now))
 			<-t.C
 
-			result, _, err := a.Send(map[string]string{"Action": "PJSIPShowContacts"})
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			contacts, err := a.PJSIPShowContacts(ctx)
+			cancel()
 			if err != nil {
 				log.Error(err)
 			} else {
 				log.Infof("******************* 当前运行次数 %d**************************", count)
-				log.Infof("PJSIPShowContacts res %+v", result)
-				// log.Infof("PJSIPShowContacts events %d %+v", len(events), events)
+				for _, contact := range contacts {
+					log.Infof("%s %s %s rtt %s %s", contact.Endpoint, contact.URI, contact.Status, contact.RoundtripUsec, contact.UserAgent)
+				}
 				log.Infof("************************************************************")
 			}
 			count = count + 1
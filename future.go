@@ -0,0 +1,143 @@
+package amigo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// Future 异步 action 的结果, 由 SendAsync 返回
+type Future struct {
+	actionID string
+	action   string
+	res      *parse.Response
+	amigo    *Amigo
+
+	mutex     sync.Mutex
+	timer     *time.Timer
+	callbacks []func(*Future)
+}
+
+func newFuture(a *Amigo, actionID, action string) *Future {
+	return &Future{
+		actionID: actionID,
+		action:   action,
+		res:      parse.NewResponse(""),
+		amigo:    a,
+	}
+}
+
+// ActionID 返回 action 的 ActionID
+func (f *Future) ActionID() string {
+	return f.actionID
+}
+
+// Done 返回完成时关闭的 channel
+func (f *Future) Done() <-chan struct{} {
+	return f.res.Complete
+}
+
+// Wait 阻塞直到 action 完成并返回结果
+func (f *Future) Wait() (data map[string]string, events []parse.Event, err error) {
+	<-f.Done()
+	return f.Result()
+}
+
+// WaitContext 与 Wait 相同, ctx 结束时放弃等待并返回 ctx.Err()
+func (f *Future) WaitContext(ctx context.Context) (data map[string]string, events []parse.Event, err error) {
+	select {
+	case <-f.Done():
+	case <-ctx.Done():
+		f.resolve(ctx.Err())
+	}
+	return f.Result()
+}
+
+// Response 返回完整的响应消息, 可按顺序读取重复的头, 完成前内容为空
+func (f *Future) Response() *parse.Response {
+	return f.res
+}
+
+// Result 返回 action 结果, 未完成时返回 utils.ErrPending
+func (f *Future) Result() (data map[string]string, events []parse.Event, err error) {
+	select {
+	case <-f.Done():
+	default:
+		return nil, nil, utils.ErrPending
+	}
+
+	if err := f.res.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	f.res.RLock()
+	defer f.res.RUnlock()
+	if len(f.res.Data) == 0 {
+		return nil, nil, errors.New("wait complete response failure, actionID: " + f.actionID)
+	}
+	return f.res.Data, f.res.Events, nil
+}
+
+// Err 返回 action 的错误, 未完成时返回 utils.ErrPending
+func (f *Future) Err() error {
+	_, _, err := f.Result()
+	return err
+}
+
+// Then 注册完成回调, 回调在独立 goroutine 中执行, 已完成时立即执行
+func (f *Future) Then(fn func(*Future)) {
+	f.mutex.Lock()
+	select {
+	case <-f.Done():
+		f.mutex.Unlock()
+		go fn(f)
+		return
+	default:
+	}
+	f.callbacks = append(f.callbacks, fn)
+	f.mutex.Unlock()
+}
+
+// expire 超时后以 utils.ErrActionTimeout 结束
+func (f *Future) expire(timeout time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.timer = time.AfterFunc(timeout, func() {
+		utils.Log.Warnf("action %s wait complete chan failure ActionTimeout: %s", f.actionID, timeout)
+		f.resolve(utils.ErrActionTimeout)
+	})
+}
+
+// completedBy 判断 event 是否为响应的最后一个事件
+// 响应带有 "EventList: start" 时等待 Complete 事件; 否则为旧版本的单结果 action,
+// 如 Asterisk 12 以前的 DBGet 只返回一个 DBGetResponse
+func (f *Future) completedBy(event *parse.Event) bool {
+	name := event.Get("Event")
+	if utils.EventComplete(name, event.Get("EventList")) {
+		return true
+	}
+	return !strings.EqualFold(f.res.Get("EventList"), "start") && name == f.action+"Response"
+}
+
+// resolve 结束 action 并从等待列表移除, err 为 nil 表示收到完整响应
+func (f *Future) resolve(err error) {
+	f.res.Fail(err)
+	f.amigo.responses.Delete(f.actionID)
+
+	f.mutex.Lock()
+	if f.timer != nil {
+		f.timer.Stop()
+	}
+	callbacks := f.callbacks
+	f.callbacks = nil
+	f.mutex.Unlock()
+
+	for _, fn := range callbacks {
+		go fn(f)
+	}
+}
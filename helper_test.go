@@ -0,0 +1,38 @@
+package amigo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+)
+
+// waitUntil 轮询 cond 直到为 true, 超时返回 false
+func waitUntil(cond func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return true
+}
+
+// newTestAmigo 连接 srv 并等待登录, 返回 Amigo 和 2 秒超时的 ctx, 断线后 10ms 重连
+// 测试结束时依次关闭 Amigo 和 srv
+func newTestAmigo(t *testing.T, srv *amitest.Server) (*Amigo, context.Context) {
+	t.Helper()
+	t.Cleanup(srv.Close)
+	a := New(&Settings{Host: srv.Host, Port: srv.Port, Username: srv.Username, Password: srv.Secret, ReconnectInterval: 10 * time.Millisecond}, nil)
+	t.Cleanup(func() { a.Close(context.Background()) })
+	a.Connect()
+	if !waitUntil(a.loggedInNow) {
+		t.Fatal("not logged in")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	t.Cleanup(cancel)
+	return a, ctx
+}
@@ -0,0 +1,216 @@
+package amigo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// OriginateReason OriginateResponse 的 Reason, 即呼叫结束时通道的控制帧
+type OriginateReason int
+
+const (
+	// OriginateFailed 呼叫失败, 如通道不可用或无此分机
+	OriginateFailed OriginateReason = 0
+	// OriginateHangup 对端挂断
+	OriginateHangup OriginateReason = 1
+	// OriginateRing 本地振铃
+	OriginateRing OriginateReason = 2
+	// OriginateNoAnswer 振铃超时未应答
+	OriginateNoAnswer OriginateReason = 3
+	// OriginateAnswered 已应答
+	OriginateAnswered OriginateReason = 4
+	// OriginateBusy 对端忙
+	OriginateBusy OriginateReason = 5
+	// OriginateCongestion 线路拥塞
+	OriginateCongestion OriginateReason = 8
+)
+
+func (r OriginateReason) String() string {
+	switch r {
+	case OriginateFailed:
+		return "Failed"
+	case OriginateHangup:
+		return "Hangup"
+	case OriginateRing:
+		return "Ring"
+	case OriginateNoAnswer:
+		return "NoAnswer"
+	case OriginateAnswered:
+		return "Answered"
+	case OriginateBusy:
+		return "Busy"
+	case OriginateCongestion:
+		return "Congestion"
+	}
+	return fmt.Sprintf("Unknown(%d)", int(r))
+}
+
+// OriginateRequest Originate 的参数, Context/Exten/Priority 与 Application/Data 二选一
+type OriginateRequest struct {
+	Channel string
+
+	Context string
+	Exten   string
+	// Priority 为空时使用 1
+	Priority string
+
+	Application string
+	Data        string
+
+	CallerID string
+	// Timeout 等待应答的时间, 为 0 时使用 Asterisk 默认值 30s
+	Timeout time.Duration
+	Account string
+	// Variables 通道变量, 按变量名排序发送
+	Variables map[string]string
+	// Codecs 如 []string{"ulaw", "alaw"}
+	Codecs     []string
+	EarlyMedia bool
+
+	// ChannelId/OtherChannelId 指定新通道及其 ;2 端的 Uniqueid
+	ChannelId      string
+	OtherChannelId string
+
+	// Async 为 true 时等待 OriginateResponse 事件, 返回结果中带有新通道的 Uniqueid
+	// 为 false 时 Asterisk 在呼叫结束后才回复, 未应答返回 *ActionError
+	Async bool
+}
+
+// Action 生成 Originate action
+func (r *OriginateRequest) Action() *Action {
+	action := NewAction("Originate").Add("Channel", r.Channel)
+	if r.Application != "" {
+		action.Add("Application", r.Application)
+		if r.Data != "" {
+			action.Add("Data", r.Data)
+		}
+	} else {
+		priority := r.Priority
+		if priority == "" {
+			priority = "1"
+		}
+		action.Add("Context", r.Context).Add("Exten", r.Exten).Add("Priority", priority)
+	}
+
+	optional := []parse.Header{
+		{Key: "CallerID", Value: r.CallerID},
+		{Key: "Account", Value: r.Account},
+		{Key: "Codecs", Value: strings.Join(r.Codecs, ",")},
+		{Key: "ChannelId", Value: r.ChannelId},
+		{Key: "OtherChannelId", Value: r.OtherChannelId},
+	}
+	if r.Timeout > 0 {
+		optional = append(optional, parse.Header{Key: "Timeout", Value: fmt.Sprint(r.Timeout.Milliseconds())})
+	}
+	if r.EarlyMedia {
+		optional = append(optional, parse.Header{Key: "EarlyMedia", Value: "true"})
+	}
+	if r.Async {
+		optional = append(optional, parse.Header{Key: "Async", Value: "true"})
+	}
+	for _, header := range optional {
+		if header.Value != "" {
+			action.Add(header.Key, header.Value)
+		}
+	}
+
+	names := make([]string, 0, len(r.Variables))
+	for name := range r.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		action.Variable(name, r.Variables[name])
+	}
+	return action
+}
+
+// OriginateResult Originate 的结果
+type OriginateResult struct {
+	// Response 异步时为 OriginateResponse 的 Response(Success/Failure), 同步时为响应的 Response
+	Response string
+	Reason   OriginateReason
+	Channel  string
+	// Uniqueid 新通道的 Uniqueid, 仅异步时有值
+	Uniqueid string
+	// Event 异步时收到的 OriginateResponse
+	Event *OriginateResponseEvent
+}
+
+// Answered 返回呼叫是否已应答
+func (r *OriginateResult) Answered() bool {
+	return r.Response == "Success" && r.Reason == OriginateAnswered
+}
+
+// Originate 发起呼叫
+// 异步时先等待 Asterisk 接受请求, 再等待对应 ActionID 的 OriginateResponse, ctx 决定最长等待时间
+//
+//	result, err := a.Originate(ctx, amigo.OriginateRequest{Channel: "PJSIP/1001", Context: "default", Exten: "1002", Async: true})
+//	if err == nil && result.Answered() { ... }
+func (a *Amigo) Originate(ctx context.Context, req OriginateRequest) (*OriginateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	actionID := utils.NewV4()
+	action := req.Action().Set("ActionID", actionID)
+
+	var responses chan *parse.Event
+	if req.Async {
+		// 先订阅, 避免错过紧随响应到达的事件
+		responses = make(chan *parse.Event, 1)
+		sub := a.Subscribe(EventFilter{
+			Names:   []string{"OriginateResponse"},
+			Headers: map[string]string{"ActionID": actionID},
+		}, func(event *parse.Event) {
+			select {
+			case responses <- event:
+			default:
+			}
+		})
+		defer sub.Unsubscribe()
+	}
+
+	data, events, err := a.send(ctx, action).WaitContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := responseError("Originate", data); err != nil {
+		return nil, err
+	}
+	if !req.Async {
+		// 同步呼叫只有应答后才会回复 Success
+		return &OriginateResult{Response: data["Response"], Reason: OriginateAnswered, Channel: req.Channel}, nil
+	}
+
+	for i := range events {
+		if events[i].Get("Event") == "OriginateResponse" {
+			return newOriginateResult(&events[i])
+		}
+	}
+	select {
+	case event := <-responses:
+		return newOriginateResult(event)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func newOriginateResult(event *parse.Event) (*OriginateResult, error) {
+	typed := &OriginateResponseEvent{}
+	if err := parse.DecodeMessage(event.Message, typed); err != nil {
+		return nil, err
+	}
+	return &OriginateResult{
+		Response: typed.Response,
+		Reason:   typed.Reason,
+		Channel:  typed.Channel,
+		Uniqueid: typed.Uniqueid,
+		Event:    typed,
+	}, nil
+}
@@ -0,0 +1,79 @@
+package amigo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+)
+
+// originateResponse 构建 OriginateResponse 事件
+func originateResponse(actionID, response string, reason OriginateReason, uniqueid string) amitest.Message {
+	return amitest.Event("OriginateResponse",
+		"ActionID", actionID,
+		"Response", response,
+		"Channel", "PJSIP/1001",
+		"Context", "ext-local",
+		"Exten", "1002",
+		"Reason", strconv.Itoa(int(reason)),
+		"Uniqueid", uniqueid,
+	)
+}
+
+func TestOriginateAsync(t *testing.T) {
+	tests := []struct {
+		name     string
+		reason   OriginateReason
+		response string
+		answered bool
+	}{
+		{name: "answered", reason: OriginateAnswered, response: "Success", answered: true},
+		{name: "busy", reason: OriginateBusy, response: "Failure"},
+		{name: "failed", reason: OriginateFailed, response: "Failure"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := amitest.NewServer()
+			srv.Handle("Originate", func(c *amitest.Conn, action amitest.Action) {
+				c.Reply(action, amitest.Response("Success", "Message", "Originate successfully queued"))
+				// 其他 Originate 的结果先到达, 应按 ActionID 忽略
+				c.Send(originateResponse("other", "Failure", OriginateCongestion, "9.9"))
+				c.Send(originateResponse(action.ActionID, tt.response, tt.reason, "1.1"))
+			})
+			a, ctx := newTestAmigo(t, srv)
+
+			result, err := a.Originate(ctx, OriginateRequest{Channel: "PJSIP/1001", Context: "ext-local", Exten: "1002", Async: true})
+			if err != nil {
+				t.Fatalf("Originate() err = %v", err)
+			}
+			if result.Response != tt.response || result.Reason != tt.reason || result.Uniqueid != "1.1" || result.Answered() != tt.answered {
+				t.Errorf("Originate() = %+v", result)
+			}
+			if result.Event == nil || result.Event.Exten != "1002" {
+				t.Errorf("Originate() event = %+v", result.Event)
+			}
+			if action, ok := srv.WaitAction("Originate", time.Second); !ok || action.Get("Async") != "true" {
+				t.Errorf("Originate action = %+v", action)
+			}
+		})
+	}
+}
+
+func TestOriginateAsyncTimeout(t *testing.T) {
+	srv := amitest.NewServer()
+	srv.Handle("Originate", func(c *amitest.Conn, action amitest.Action) {
+		c.Reply(action, amitest.Response("Success", "Message", "Originate successfully queued"))
+		c.Send(originateResponse("other", "Success", OriginateAnswered, "9.9"))
+	})
+	a, _ := newTestAmigo(t, srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := a.Originate(ctx, OriginateRequest{Channel: "PJSIP/1001", Application: "Playback", Data: "demo", Async: true}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Originate() err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
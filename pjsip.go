@@ -0,0 +1,512 @@
+package amigo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// decodeList 将列表 action 返回的事件中名为 name 的事件依次交给 decode
+func decodeList(events []parse.Event, name string, decode func(*parse.Message) error) error {
+	for i := range events {
+		if events[i].Get("Event") != name {
+			continue
+		}
+		if err := decode(events[i].Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PJSIPShowEndpoints 返回全部 PJSIP endpoint
+func (a *Amigo) PJSIPShowEndpoints(ctx context.Context) ([]EndpointListEvent, error) {
+	_, events, err := a.request(ctx, NewAction("PJSIPShowEndpoints"))
+	if err != nil {
+		return nil, err
+	}
+	var list []EndpointListEvent
+	err = decodeList(events, "EndpointList", func(m *parse.Message) error {
+		var item EndpointListEvent
+		err := parse.DecodeMessage(m, &item)
+		list = append(list, item)
+		return err
+	})
+	return list, err
+}
+
+// PJSIPEndpoint PJSIPShowEndpoint 的结果
+type PJSIPEndpoint struct {
+	Endpoint   EndpointDetailEvent
+	Aors       []AorDetailEvent
+	Auths      []AuthDetailEvent
+	Transports []TransportDetailEvent
+	Identifies []IdentifyDetailEvent
+	Contacts   []ContactStatusDetailEvent
+}
+
+// PJSIPShowEndpoint 返回一个 endpoint 及其 AOR、认证、传输、identify 和联系人
+func (a *Amigo) PJSIPShowEndpoint(ctx context.Context, endpoint string) (*PJSIPEndpoint, error) {
+	_, events, err := a.request(ctx, NewAction("PJSIPShowEndpoint").Add("Endpoint", endpoint))
+	if err != nil {
+		return nil, err
+	}
+	result := &PJSIPEndpoint{}
+	for i := range events {
+		var err error
+		switch events[i].Get("Event") {
+		case "EndpointDetail":
+			err = parse.DecodeMessage(events[i].Message, &result.Endpoint)
+		case "AorDetail":
+			var item AorDetailEvent
+			err = parse.DecodeMessage(events[i].Message, &item)
+			result.Aors = append(result.Aors, item)
+		case "AuthDetail":
+			var item AuthDetailEvent
+			err = parse.DecodeMessage(events[i].Message, &item)
+			result.Auths = append(result.Auths, item)
+		case "TransportDetail":
+			var item TransportDetailEvent
+			err = parse.DecodeMessage(events[i].Message, &item)
+			result.Transports = append(result.Transports, item)
+		case "IdentifyDetail":
+			var item IdentifyDetailEvent
+			err = parse.DecodeMessage(events[i].Message, &item)
+			result.Identifies = append(result.Identifies, item)
+		case "ContactStatusDetail":
+			var item ContactStatusDetailEvent
+			err = parse.DecodeMessage(events[i].Message, &item)
+			result.Contacts = append(result.Contacts, item)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// PJSIPShowAors 返回全部 AOR
+func (a *Amigo) PJSIPShowAors(ctx context.Context) ([]AorListEvent, error) {
+	_, events, err := a.request(ctx, NewAction("PJSIPShowAors"))
+	if err != nil {
+		return nil, err
+	}
+	var list []AorListEvent
+	err = decodeList(events, "AorList", func(m *parse.Message) error {
+		var item AorListEvent
+		err := parse.DecodeMessage(m, &item)
+		list = append(list, item)
+		return err
+	})
+	return list, err
+}
+
+// PJSIPShowContacts 返回全部联系人, 包括注册的和静态配置的
+func (a *Amigo) PJSIPShowContacts(ctx context.Context) ([]ContactListEvent, error) {
+	_, events, err := a.request(ctx, NewAction("PJSIPShowContacts"))
+	if err != nil {
+		return nil, err
+	}
+	var list []ContactListEvent
+	err = decodeList(events, "ContactList", func(m *parse.Message) error {
+		var item ContactListEvent
+		err := parse.DecodeMessage(m, &item)
+		list = append(list, item)
+		return err
+	})
+	return list, err
+}
+
+// PJSIPShowRegistrationsOutbound 返回向外注册的状态
+func (a *Amigo) PJSIPShowRegistrationsOutbound(ctx context.Context) ([]OutboundRegistrationDetailEvent, error) {
+	_, events, err := a.request(ctx, NewAction("PJSIPShowRegistrationsOutbound"))
+	if err != nil {
+		return nil, err
+	}
+	var list []OutboundRegistrationDetailEvent
+	err = decodeList(events, "OutboundRegistrationDetail", func(m *parse.Message) error {
+		var item OutboundRegistrationDetailEvent
+		err := parse.DecodeMessage(m, &item)
+		list = append(list, item)
+		return err
+	})
+	return list, err
+}
+
+// PJSIPShowRegistrationsInbound 返回有注册联系人的 AOR
+func (a *Amigo) PJSIPShowRegistrationsInbound(ctx context.Context) ([]InboundRegistrationDetailEvent, error) {
+	_, events, err := a.request(ctx, NewAction("PJSIPShowRegistrationsInbound"))
+	if err != nil {
+		return nil, err
+	}
+	var list []InboundRegistrationDetailEvent
+	err = decodeList(events, "InboundRegistrationDetail", func(m *parse.Message) error {
+		var item InboundRegistrationDetailEvent
+		err := parse.DecodeMessage(m, &item)
+		list = append(list, item)
+		return err
+	})
+	return list, err
+}
+
+// ContactStatus 取值, 与 ContactStatus 事件和 ContactList 的 Status 相同
+const (
+	ContactReachable    = "Reachable"
+	ContactUnreachable  = "Unreachable"
+	ContactNonQualified = "NonQualified"
+	ContactUnknown      = "Unknown"
+	ContactCreated      = "Created"
+	ContactUpdated      = "Updated"
+	ContactRemoved      = "Removed"
+)
+
+// EndpointContact endpoint 的一个联系人
+type EndpointContact struct {
+	URI       string
+	AOR       string
+	Status    string
+	RoundTrip time.Duration
+	UserAgent string
+	RegExpire time.Time
+	Via       string
+}
+
+// Reachable 联系人可达, 未开启 qualify 的注册联系人视为可达
+func (c *EndpointContact) Reachable() bool {
+	return c.Status == ContactReachable || c.Status == ContactNonQualified
+}
+
+// EndpointStatus endpoint 的在线状态
+type EndpointStatus struct {
+	Name        string
+	DeviceState DeviceState
+	// Contacts 按 URI 排序
+	Contacts []EndpointContact
+	Updated  time.Time
+}
+
+// Online 有可达的联系人且设备不是 UNAVAILABLE
+func (e *EndpointStatus) Online() bool {
+	if !e.DeviceState.Online() {
+		return false
+	}
+	for i := range e.Contacts {
+		if e.Contacts[i].Reachable() {
+			return true
+		}
+	}
+	return false
+}
+
+// RTT 返回可达联系人中最小的往返时间, 没有时返回 0
+func (e *EndpointStatus) RTT() time.Duration {
+	var rtt time.Duration
+	for i := range e.Contacts {
+		c := &e.Contacts[i]
+		if c.Status == ContactReachable && c.RoundTrip > 0 && (rtt == 0 || c.RoundTrip < rtt) {
+			rtt = c.RoundTrip
+		}
+	}
+	return rtt
+}
+
+// UserAgents 返回已注册联系人的 User-Agent, 去重并排序
+func (e *EndpointStatus) UserAgents() []string {
+	seen := make(map[string]bool)
+	var agents []string
+	for i := range e.Contacts {
+		agent := e.Contacts[i].UserAgent
+		if agent != "" && !seen[agent] {
+			seen[agent] = true
+			agents = append(agents, agent)
+		}
+	}
+	sort.Strings(agents)
+	return agents
+}
+
+type trackedEndpoint struct {
+	EndpointStatus
+	contacts map[string]*EndpointContact
+}
+
+func (e *trackedEndpoint) snapshot() EndpointStatus {
+	status := e.EndpointStatus
+	status.Contacts = make([]EndpointContact, 0, len(e.contacts))
+	for _, contact := range e.contacts {
+		status.Contacts = append(status.Contacts, *contact)
+	}
+	sort.Slice(status.Contacts, func(i, j int) bool {
+		return status.Contacts[i].URI < status.Contacts[j].URI
+	})
+	return status
+}
+
+func (e *trackedEndpoint) contact(uri string) *EndpointContact {
+	contact, ok := e.contacts[uri]
+	if !ok {
+		contact = &EndpointContact{URI: uri, Status: ContactUnknown}
+		e.contacts[uri] = contact
+	}
+	return contact
+}
+
+// EndpointChange Endpoints 的变更通知
+type EndpointChange struct {
+	Endpoint EndpointStatus
+	// WasOnline 变更前是否在线, 与 Endpoint.Online() 比较可得到上线/下线
+	WasOnline bool
+	// Event 引起变更的事件, 由 PJSIPShowEndpoints 同步产生的变更为 nil
+	Event Event
+}
+
+var endpointEvents = []string{"ContactStatus", "DeviceStateChange"}
+
+// Endpoints 维护 PJSIP endpoint 的注册、可达性和设备状态
+// 每次登录后通过 PJSIPShowEndpoints/PJSIPShowContacts 重新同步, 之后由 ContactStatus、DeviceStateChange 事件更新
+type Endpoints struct {
+	tracker
+	endpoints map[string]*trackedEndpoint
+}
+
+// NewEndpoints 新建 Endpoints 并开始跟踪, 已登录时立即同步
+func NewEndpoints(a *Amigo) *Endpoints {
+	e := &Endpoints{endpoints: make(map[string]*trackedEndpoint)}
+	e.start(a, "endpoints", endpointEvents, e.handle, e.seed)
+	return e
+}
+
+// Close 停止跟踪和重新同步, Get/Endpoints 仍返回最后的状态
+func (e *Endpoints) Close() {
+	e.close()
+}
+
+// Get 按名称查找 endpoint
+func (e *Endpoints) Get(name string) (EndpointStatus, bool) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if endpoint, ok := e.endpoints[name]; ok {
+		return endpoint.snapshot(), true
+	}
+	return EndpointStatus{}, false
+}
+
+// Endpoints 返回全部 endpoint, 按名称排序
+func (e *Endpoints) Endpoints() []EndpointStatus {
+	return e.Filter(nil)
+}
+
+// Offline 返回当前离线的 endpoint, 按名称排序
+func (e *Endpoints) Offline() []EndpointStatus {
+	return e.Filter(func(status *EndpointStatus) bool {
+		return !status.Online()
+	})
+}
+
+// Filter 返回 match 为 true 的 endpoint, 按名称排序, match 为 nil 时返回全部
+func (e *Endpoints) Filter(match func(*EndpointStatus) bool) []EndpointStatus {
+	e.mutex.RLock()
+	list := make([]EndpointStatus, 0, len(e.endpoints))
+	for _, endpoint := range e.endpoints {
+		status := endpoint.snapshot()
+		if match == nil || match(&status) {
+			list = append(list, status)
+		}
+	}
+	e.mutex.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// Watch 注册 endpoint 的联系人和设备状态变更通知, 执行方式见 ChannelTracker.Watch
+func (e *Endpoints) Watch(fn func(EndpointChange)) Subscription {
+	return e.watchers.add(func(v interface{}) {
+		fn(v.(EndpointChange))
+	})
+}
+
+func (e *Endpoints) endpoint(name string) *trackedEndpoint {
+	endpoint, ok := e.endpoints[name]
+	if !ok {
+		endpoint = &trackedEndpoint{
+			EndpointStatus: EndpointStatus{Name: name, DeviceState: DeviceUnknown},
+			contacts:       make(map[string]*EndpointContact),
+		}
+		e.endpoints[name] = endpoint
+	}
+	return endpoint
+}
+
+func (e *Endpoints) handle(event *parse.Event) {
+	typed, err := DecodeEvent(event)
+	if err != nil {
+		utils.Log.Errorf("endpoints decode %s", err)
+		return
+	}
+
+	if !e.lock() {
+		return
+	}
+	var endpoint *trackedEndpoint
+	var before EndpointStatus
+	switch ev := typed.(type) {
+	case *ContactStatusEvent:
+		if ev.EndpointName == "" {
+			break
+		}
+		endpoint = e.endpoint(ev.EndpointName)
+		before = endpoint.snapshot()
+		e.touch(endpointContactKey(ev.EndpointName, ev.URI))
+		if ev.ContactStatus == ContactRemoved {
+			delete(endpoint.contacts, ev.URI)
+			break
+		}
+		contact := endpoint.contact(ev.URI)
+		contact.AOR = ev.AOR
+		// Created/Updated 只表示注册变化, 保留之前的可达性
+		if ev.ContactStatus != ContactCreated && ev.ContactStatus != ContactUpdated {
+			contact.Status = ev.ContactStatus
+		}
+		if ev.RoundtripUsec > 0 || ev.ContactStatus == ContactUnreachable {
+			contact.RoundTrip = ev.RoundtripUsec
+		}
+		if ev.UserAgent != "" {
+			contact.UserAgent = ev.UserAgent
+		}
+		if !ev.RegExpire.IsZero() {
+			contact.RegExpire = ev.RegExpire
+		}
+		if ev.ViaAddress != "" {
+			contact.Via = ev.ViaAddress
+		}
+	case *DeviceStateChangeEvent:
+		name := strings.TrimPrefix(ev.Device, "PJSIP/")
+		if name == ev.Device {
+			break
+		}
+		endpoint = e.endpoint(name)
+		before = endpoint.snapshot()
+		e.touch(endpointDeviceKey(name))
+		endpoint.DeviceState = ParseDeviceState(ev.State)
+	}
+	if endpoint == nil {
+		e.mutex.Unlock()
+		return
+	}
+	endpoint.Updated = time.Now()
+	change := EndpointChange{Endpoint: endpoint.snapshot(), WasOnline: before.Online(), Event: typed}
+	e.mutex.Unlock()
+
+	e.watchers.notify(change)
+}
+
+// seed 通过 PJSIPShowEndpoints/PJSIPShowContacts 重新同步全部 endpoint, 合并方式见 merge
+func (e *Endpoints) seed() {
+	var list []EndpointListEvent
+	var contacts []ContactListEvent
+	var changes []EndpointChange
+	e.resync(func(ctx context.Context) (err error) {
+		if list, err = e.amigo.PJSIPShowEndpoints(ctx); err != nil {
+			return err
+		}
+		contacts, err = e.amigo.PJSIPShowContacts(ctx)
+		return err
+	}, func(touched map[string]bool) {
+		now := time.Now()
+		endpoints := make(map[string]*trackedEndpoint, len(list))
+		for i := range list {
+			endpoints[list[i].ObjectName] = &trackedEndpoint{
+				EndpointStatus: EndpointStatus{
+					Name:        list[i].ObjectName,
+					DeviceState: ParseDeviceState(list[i].DeviceState),
+					Updated:     now,
+				},
+				contacts: make(map[string]*EndpointContact),
+			}
+		}
+		for i := range contacts {
+			c := &contacts[i]
+			endpoint, ok := endpoints[c.Endpoint]
+			if !ok {
+				continue
+			}
+			endpoint.contacts[c.URI] = &EndpointContact{
+				URI:       c.URI,
+				AOR:       c.Aor,
+				Status:    c.Status,
+				RoundTrip: c.RoundtripUsec,
+				UserAgent: c.UserAgent,
+				RegExpire: c.ExpirationTime,
+				Via:       c.ViaAddr,
+			}
+		}
+
+		changes = e.merge(endpoints, touched)
+	})
+
+	for _, change := range changes {
+		e.watchers.notify(change)
+	}
+}
+
+// merge 合并同步结果, 同步期间有 DeviceStateChange 的设备状态和有 ContactStatus 的联系人以事件为准,
+// 返回新增和在线状态改变的 endpoint
+func (e *Endpoints) merge(endpoints map[string]*trackedEndpoint, touched map[string]bool) []EndpointChange {
+	var changes []EndpointChange
+	for name, listed := range endpoints {
+		_, existed := e.endpoints[name]
+		endpoint := e.endpoint(name)
+		before := endpoint.snapshot()
+		if !touched[endpointDeviceKey(name)] {
+			endpoint.DeviceState = listed.DeviceState
+		}
+		for uri, contact := range listed.contacts {
+			if !touched[endpointContactKey(name, uri)] {
+				endpoint.contacts[uri] = contact
+			}
+		}
+		for uri := range endpoint.contacts {
+			if _, ok := listed.contacts[uri]; !ok && !touched[endpointContactKey(name, uri)] {
+				delete(endpoint.contacts, uri)
+			}
+		}
+		endpoint.Updated = listed.Updated
+
+		status := endpoint.snapshot()
+		if !existed || before.Online() != status.Online() {
+			changes = append(changes, EndpointChange{Endpoint: status, WasOnline: existed && before.Online()})
+		}
+	}
+
+	// 已删除的 endpoint 只保留同步期间有事件的部分
+	for name, endpoint := range e.endpoints {
+		if _, ok := endpoints[name]; ok {
+			continue
+		}
+		for uri := range endpoint.contacts {
+			if !touched[endpointContactKey(name, uri)] {
+				delete(endpoint.contacts, uri)
+			}
+		}
+		if !touched[endpointDeviceKey(name)] && len(endpoint.contacts) == 0 {
+			delete(e.endpoints, name)
+		}
+	}
+	return changes
+}
+
+// 重新同步时登记事件改变的对象的 key
+func endpointDeviceKey(name string) string {
+	return "device:" + name
+}
+
+func endpointContactKey(name, uri string) string {
+	return "contact:" + name + "/" + uri
+}
@@ -0,0 +1,127 @@
+package amigo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+)
+
+// endpointNames 返回 endpoint 的名称
+func endpointNames(endpoints []EndpointStatus) []string {
+	names := make([]string, 0, len(endpoints))
+	for i := range endpoints {
+		names = append(names, endpoints[i].Name)
+	}
+	return names
+}
+
+// contactStatus 构建 ContactStatus 事件
+func contactStatus(endpoint, uri, status string, kv ...string) amitest.Message {
+	return amitest.Event("ContactStatus", append([]string{
+		"URI", uri,
+		"ContactStatus", status,
+		"AOR", endpoint,
+		"EndpointName", endpoint,
+	}, kv...)...)
+}
+
+func TestEndpoints(t *testing.T) {
+	srv := amitest.NewServer()
+	srv.RespondList("PJSIPShowEndpoints", []amitest.Message{
+		amitest.Event("EndpointList", "ObjectName", "1001", "DeviceState", "Not in use"),
+		amitest.Event("EndpointList", "ObjectName", "1002", "DeviceState", "Unavailable"),
+		amitest.Event("EndpointList", "ObjectName", "1003", "DeviceState", "Not in use"),
+	}, amitest.Event("EndpointListComplete"))
+	srv.RespondList("PJSIPShowContacts", []amitest.Message{
+		amitest.Event("ContactList", "Endpoint", "1001", "Aor", "1001", "Uri", "sip:1001@10.0.0.1:5060",
+			"Status", "Reachable", "RoundtripUsec", "12000", "UserAgent", "Yealink"),
+		amitest.Event("ContactList", "Endpoint", "1003", "Aor", "1003", "Uri", "sip:1003@10.0.0.3:5060",
+			"Status", "Unreachable", "RoundtripUsec", "0"),
+	}, amitest.Event("ContactListComplete"))
+	a, _ := newTestAmigo(t, srv)
+	endpoints := NewEndpoints(a)
+	defer endpoints.Close()
+
+	if !waitUntil(func() bool { return len(endpoints.Endpoints()) == 3 }) {
+		t.Fatalf("Endpoints() = %+v", endpoints.Endpoints())
+	}
+	if got := endpointNames(endpoints.Offline()); !reflect.DeepEqual(got, []string{"1002", "1003"}) {
+		t.Errorf("Offline() = %v after seed", got)
+	}
+	if e, _ := endpoints.Get("1001"); e.RTT() != 12*time.Millisecond || !reflect.DeepEqual(e.UserAgents(), []string{"Yealink"}) {
+		t.Errorf("Get(1001) = %+v, RTT() = %v", e, e.RTT())
+	}
+
+	changes := make(chan EndpointChange, 16)
+	endpoints.Watch(func(change EndpointChange) { changes <- change })
+
+	steps := []struct {
+		name      string
+		event     amitest.Message
+		endpoint  string
+		wasOnline bool
+		online    bool
+		rtt       time.Duration
+		offline   []string
+	}{
+		{
+			name:     "1003 reachable",
+			event:    contactStatus("1003", "sip:1003@10.0.0.3:5060", "Reachable", "RoundtripUsec", "30000"),
+			endpoint: "1003", online: true, rtt: 30 * time.Millisecond,
+			offline: []string{"1002"},
+		},
+		{
+			name:     "1003 second contact with lower RTT",
+			event:    contactStatus("1003", "sip:1003@10.0.0.4:5060", "Reachable", "RoundtripUsec", "5000"),
+			endpoint: "1003", wasOnline: true, online: true, rtt: 5 * time.Millisecond,
+			offline: []string{"1002"},
+		},
+		{
+			name:     "1001 unreachable",
+			event:    contactStatus("1001", "sip:1001@10.0.0.1:5060", "Unreachable"),
+			endpoint: "1001", wasOnline: true,
+			offline: []string{"1001", "1002"},
+		},
+		{
+			name:     "1002 registers",
+			event:    contactStatus("1002", "sip:1002@10.0.0.2:5060", "Created", "UserAgent", "Zoiper"),
+			endpoint: "1002",
+			offline:  []string{"1001", "1002"},
+		},
+		{
+			name:     "1002 device available",
+			event:    amitest.Event("DeviceStateChange", "Device", "PJSIP/1002", "State", "NOT_INUSE"),
+			endpoint: "1002",
+			offline:  []string{"1001", "1002"},
+		},
+		{
+			name:     "1002 qualified",
+			event:    contactStatus("1002", "sip:1002@10.0.0.2:5060", "Reachable", "RoundtripUsec", "8000"),
+			endpoint: "1002", online: true, rtt: 8 * time.Millisecond,
+			offline: []string{"1001"},
+		},
+		{
+			name:     "1003 removed contact",
+			event:    contactStatus("1003", "sip:1003@10.0.0.4:5060", "Removed"),
+			endpoint: "1003", wasOnline: true, online: true, rtt: 30 * time.Millisecond,
+			offline: []string{"1001"},
+		},
+	}
+	for _, step := range steps {
+		srv.Inject(step.event)
+		select {
+		case change := <-changes:
+			e := change.Endpoint
+			if e.Name != step.endpoint || change.WasOnline != step.wasOnline || e.Online() != step.online || e.RTT() != step.rtt {
+				t.Errorf("%s: change = %+v, WasOnline %v, Online() %v, RTT() %v", step.name, e, change.WasOnline, e.Online(), e.RTT())
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: no change", step.name)
+		}
+		if got := endpointNames(endpoints.Offline()); !reflect.DeepEqual(got, step.offline) {
+			t.Errorf("%s: Offline() = %v, want %v", step.name, got, step.offline)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package amitest
+
+import "strings"
+
+// Header 一个 AMI 头
+type Header struct {
+	Key   string
+	Value string
+}
+
+// Message 按顺序排列的 AMI 头, 可重复
+type Message []Header
+
+// NewMessage 由 key, value 交替的参数构建消息
+func NewMessage(kv ...string) Message {
+	m := make(Message, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		m = append(m, Header{Key: kv[i], Value: kv[i+1]})
+	}
+	return m
+}
+
+// Response 构建 "Response: status" 开头的响应
+func Response(status string, kv ...string) Message {
+	return append(Message{{Key: "Response", Value: status}}, NewMessage(kv...)...)
+}
+
+// Event 构建 "Event: name" 开头的事件
+func Event(name string, kv ...string) Message {
+	return append(Message{{Key: "Event", Value: name}}, NewMessage(kv...)...)
+}
+
+// With 返回追加了一个头的新消息
+func (m Message) With(key, value string) Message {
+	result := make(Message, len(m), len(m)+1)
+	copy(result, m)
+	return append(result, Header{Key: key, Value: value})
+}
+
+// Get 返回第一个匹配的头, 不区分大小写
+func (m Message) Get(key string) string {
+	for _, h := range m {
+		if strings.EqualFold(h.Key, key) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// GetAll 返回所有匹配的头, 不区分大小写
+func (m Message) GetAll(key string) []string {
+	var values []string
+	for _, h := range m {
+		if strings.EqualFold(h.Key, key) {
+			values = append(values, h.Value)
+		}
+	}
+	return values
+}
+
+// String 按 AMI 格式编码, 以空行结束
+func (m Message) String() string {
+	var b strings.Builder
+	for _, h := range m {
+		b.WriteString(h.Key)
+		b.WriteString(": ")
+		b.WriteString(h.Value)
+		b.WriteString("\r\n")
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// Action 客户端发送的 action
+type Action struct {
+	Message
+	Name     string
+	ActionID string
+}
@@ -0,0 +1,349 @@
+// Package amitest 提供进程内的 Asterisk manager 模拟服务, 用于在没有 Asterisk 的环境下测试 Amigo
+//
+//	srv := amitest.NewServer()
+//	defer srv.Close()
+//	srv.Respond("CoreStatus", amitest.Response("Success", "CoreCurrentCalls", "3"))
+//	a := amigo.New(&amigo.Settings{Host: srv.Host, Port: srv.Port, Username: srv.Username, Password: srv.Secret}, nil)
+package amitest
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/pkg/record"
+)
+
+const (
+	// DefaultBanner 连接建立后发送的欢迎信息
+	DefaultBanner = "Asterisk Call Manager/5.0.1"
+	// DefaultUsername 默认登录用户名
+	DefaultUsername = "amitest"
+	// DefaultSecret 默认登录密码
+	DefaultSecret = "amitest"
+)
+
+// Handler 处理客户端发送的 action, 通过 Conn 回复响应或事件
+type Handler func(c *Conn, action Action)
+
+// Server 模拟 Asterisk manager 的 TCP 服务
+// 内置 Login(明文/MD5)、Challenge、Ping、Logoff, 其他 action 通过 Handle/Respond 注册
+type Server struct {
+	// Addr 监听地址 host:port
+	Addr string
+	Host string
+	Port string
+
+	// Username/Secret 登录校验, 在客户端连接前修改
+	Username string
+	Secret   string
+	// Banner 欢迎信息, 在客户端连接前修改
+	Banner string
+
+	listener net.Listener
+	mutex    sync.Mutex
+	handlers map[string]Handler
+	conns    map[*Conn]struct{}
+	received []Action
+	wg       sync.WaitGroup
+}
+
+// NewServer 在 127.0.0.1 随机端口启动模拟服务
+func NewServer() *Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("amitest: failed to listen: %v", err))
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		Host:     host,
+		Port:     port,
+		Username: DefaultUsername,
+		Secret:   DefaultSecret,
+		Banner:   DefaultBanner,
+		listener: listener,
+		handlers: make(map[string]Handler),
+		conns:    make(map[*Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Close 停止监听并断开所有连接
+func (s *Server) Close() {
+	s.listener.Close()
+	s.CloseConnections()
+	s.wg.Wait()
+}
+
+// CloseConnections 直接断开所有客户端连接, 模拟网络中断或 Asterisk 重启
+func (s *Server) CloseConnections() {
+	for _, c := range s.connections() {
+		c.Close()
+	}
+}
+
+// Handle 注册 action 的处理函数, action 名不区分大小写, 覆盖内置处理
+func (s *Server) Handle(action string, handler Handler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.handlers[strings.ToLower(action)] = handler
+}
+
+// Respond 注册 action 的固定响应
+func (s *Server) Respond(action string, response Message) {
+	s.Handle(action, func(c *Conn, a Action) {
+		c.Reply(a, response)
+	})
+}
+
+// RespondList 注册返回事件列表的 action:
+// 先回复 EventList: start 的响应, 再依次发送 events, 最后发送 complete 事件
+func (s *Server) RespondList(action string, events []Message, complete Message) {
+	s.Handle(action, func(c *Conn, a Action) {
+		c.Reply(a, Response("Success", "EventList", "start", "Message", "Result will follow"))
+		for _, event := range events {
+			c.Reply(a, event)
+		}
+		c.Reply(a, complete.With("EventList", "Complete").With("ListItems", fmt.Sprint(len(events))))
+	})
+}
+
+// Delay 注册延迟 d 后回复的固定响应, 不阻塞同一连接上的其他 action
+func (s *Server) Delay(action string, d time.Duration, response Message) {
+	s.Handle(action, func(c *Conn, a Action) {
+		time.AfterFunc(d, func() {
+			c.Reply(a, response)
+		})
+	})
+}
+
+// Drop 注册不回复的 action
+func (s *Server) Drop(action string) {
+	s.Handle(action, func(c *Conn, a Action) {})
+}
+
+// Inject 向所有已连接的客户端发送事件
+func (s *Server) Inject(event Message) {
+	for _, c := range s.connections() {
+		c.Send(event)
+	}
+}
+
+// Replay 将 record.Recorder 记录的 Asterisk 事件帧发送给所有已连接的客户端
+// 记录中的响应帧对应原会话的 ActionID, 不会发送. speed 含义同 record.Replay
+func (s *Server) Replay(ctx context.Context, r io.Reader, speed float64) error {
+	return record.Replay(ctx, r, record.In, speed, func(frame record.Frame) error {
+		if !strings.HasPrefix(frame.Data, "Event:") {
+			return nil
+		}
+		for _, c := range s.connections() {
+			c.SendRaw(frame.Data + "\r\n\r\n")
+		}
+		return nil
+	})
+}
+
+// Received 返回已收到的全部 action, 按收到顺序排列
+func (s *Server) Received() []Action {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Action(nil), s.received...)
+}
+
+// WaitAction 等待收到指定名称的 action, 超时返回 false
+func (s *Server) WaitAction(action string, timeout time.Duration) (Action, bool) {
+	deadline := time.Now().Add(timeout)
+	for {
+		for _, a := range s.Received() {
+			if strings.EqualFold(a.Name, action) {
+				return a, true
+			}
+		}
+		if time.Now().After(deadline) {
+			return Action{}, false
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Connections 返回当前连接数
+func (s *Server) Connections() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.conns)
+}
+
+func (s *Server) connections() []*Conn {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	conns := make([]*Conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		netConn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		c := &Conn{conn: netConn, server: s}
+		s.mutex.Lock()
+		s.conns[c] = struct{}{}
+		s.mutex.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			c.serve()
+		}()
+	}
+}
+
+func (s *Server) handler(action string) Handler {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if handler, ok := s.handlers[strings.ToLower(action)]; ok {
+		return handler
+	}
+	return builtin[strings.ToLower(action)]
+}
+
+var builtin = map[string]Handler{
+	"challenge": func(c *Conn, a Action) {
+		if !strings.EqualFold(a.Get("AuthType"), "MD5") {
+			c.Reply(a, Response("Error", "Message", "Must specify AuthType"))
+			return
+		}
+		c.challenge = fmt.Sprint(time.Now().UnixNano())
+		c.Reply(a, Response("Success", "Challenge", c.challenge))
+	},
+	"login": func(c *Conn, a Action) {
+		s := c.server
+		ok := a.Get("Username") == s.Username
+		if strings.EqualFold(a.Get("AuthType"), "MD5") {
+			sum := md5.Sum([]byte(c.challenge + s.Secret))
+			ok = ok && c.challenge != "" && a.Get("Key") == hex.EncodeToString(sum[:])
+		} else {
+			ok = ok && a.Get("Secret") == s.Secret
+		}
+		if !ok {
+			c.Reply(a, Response("Error", "Message", "Authentication failed"))
+			return
+		}
+		c.Reply(a, Response("Success", "Message", "Authentication accepted"))
+		c.Send(Event("FullyBooted", "Privilege", "system,all", "Status", "Fully Booted"))
+	},
+	"ping": func(c *Conn, a Action) {
+		c.Reply(a, Response("Success", "Ping", "Pong", "Timestamp", fmt.Sprintf("%.6f", float64(time.Now().UnixNano())/1e9)))
+	},
+	"logoff": func(c *Conn, a Action) {
+		c.Reply(a, Response("Goodbye", "Message", "Thanks for all the fish."))
+		c.Close()
+	},
+}
+
+// Conn 一个客户端连接
+type Conn struct {
+	conn      net.Conn
+	server    *Server
+	writeLock sync.Mutex
+	closeOnce sync.Once
+	challenge string
+}
+
+// Send 发送一条消息
+func (c *Conn) Send(m Message) error {
+	return c.SendRaw(m.String())
+}
+
+// SendRaw 原样发送数据, 用于构造异常格式的帧
+func (c *Conn) SendRaw(data string) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	_, err := c.conn.Write([]byte(data))
+	return err
+}
+
+// Reply 回复 action, 自动带上 action 的 ActionID
+func (c *Conn) Reply(a Action, m Message) error {
+	if a.ActionID != "" {
+		m = m.With("ActionID", a.ActionID)
+	}
+	return c.Send(m)
+}
+
+// Close 直接断开连接
+func (c *Conn) Close() {
+	c.closeOnce.Do(func() {
+		c.conn.Close()
+		c.server.mutex.Lock()
+		delete(c.server.conns, c)
+		c.server.mutex.Unlock()
+	})
+}
+
+func (c *Conn) serve() {
+	defer c.Close()
+	if _, err := c.conn.Write([]byte(c.server.Banner + "\r\n")); err != nil {
+		return
+	}
+
+	scanner := parse.NewFrameScanner(c.conn, 0)
+	for {
+		action, err := readAction(scanner)
+		if err == parse.ErrFrameTooLarge {
+			continue
+		}
+		if err != nil {
+			return
+		}
+
+		c.server.mutex.Lock()
+		c.server.received = append(c.server.received, *action)
+		c.server.mutex.Unlock()
+
+		if handler := c.server.handler(action.Name); handler != nil {
+			handler(c, *action)
+		} else {
+			c.Reply(*action, Response("Error", "Message", "Invalid/unknown command"))
+		}
+	}
+}
+
+func readAction(scanner *parse.FrameScanner) (*Action, error) {
+	frame, err := scanner.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	var headers Message
+	for _, line := range strings.Split(frame, "\r\n") {
+		parts := strings.SplitN(line, ":", 2)
+		value := ""
+		if len(parts) == 2 {
+			value = strings.TrimSpace(parts[1])
+		}
+		headers = append(headers, Header{Key: strings.TrimSpace(parts[0]), Value: value})
+	}
+	return &Action{
+		Message:  headers,
+		Name:     headers.Get("Action"),
+		ActionID: headers.Get("ActionID"),
+	}, nil
+}
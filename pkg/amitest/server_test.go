@@ -0,0 +1,194 @@
+package amitest
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+)
+
+// dial 连接 srv 并读掉欢迎信息
+func dial(t *testing.T, srv *Server) (net.Conn, *parse.FrameScanner) {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+	scanner := parse.NewFrameScanner(conn, 0)
+	banner, err := scanner.ReadLine()
+	if err != nil || banner != srv.Banner {
+		t.Fatalf("banner = %q, %v", banner, err)
+	}
+	return conn, scanner
+}
+
+// exchange 发送 action 并读取 n 个回复帧
+func exchange(t *testing.T, conn net.Conn, scanner *parse.FrameScanner, action Message, n int) []string {
+	t.Helper()
+	if _, err := conn.Write([]byte(action.String())); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	frames := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		frame, err := scanner.Next()
+		if err != nil {
+			t.Fatalf("read frame %d: %v", i, err)
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+func TestServerLogin(t *testing.T) {
+	tests := []struct {
+		name   string
+		md5    bool
+		user   string
+		secret string
+		want   string
+	}{
+		{name: "plain", user: DefaultUsername, secret: DefaultSecret, want: "Response: Success"},
+		{name: "plain wrong secret", user: DefaultUsername, secret: "wrong", want: "Response: Error"},
+		{name: "plain wrong user", user: "admin", secret: DefaultSecret, want: "Response: Error"},
+		{name: "md5", md5: true, user: DefaultUsername, secret: DefaultSecret, want: "Response: Success"},
+		{name: "md5 wrong secret", md5: true, user: DefaultUsername, secret: "wrong", want: "Response: Error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer()
+			defer srv.Close()
+			conn, scanner := dial(t, srv)
+			defer conn.Close()
+
+			login := NewMessage("Action", "Login", "ActionID", "2", "Username", tt.user)
+			if tt.md5 {
+				frames := exchange(t, conn, scanner, NewMessage("Action", "Challenge", "ActionID", "1", "AuthType", "MD5"), 1)
+				challenge := parse.NewResponse(frames[0]).Get("Challenge")
+				if challenge == "" {
+					t.Fatalf("challenge response = %q", frames[0])
+				}
+				sum := md5.Sum([]byte(challenge + tt.secret))
+				login = login.With("AuthType", "MD5").With("Key", hex.EncodeToString(sum[:]))
+			} else {
+				login = login.With("Secret", tt.secret)
+			}
+
+			frames := exchange(t, conn, scanner, login, 1)
+			if !strings.HasPrefix(frames[0], tt.want) || !strings.Contains(frames[0], "ActionID: 2") {
+				t.Errorf("login response = %q, want %s", frames[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestServerActions(t *testing.T) {
+	tests := []struct {
+		name   string
+		setup  func(srv *Server)
+		action string
+		want   []string
+	}{
+		{
+			name:   "builtin ping",
+			action: "Ping",
+			want:   []string{"Response: Success\r\nPing: Pong"},
+		},
+		{
+			name:   "unknown",
+			action: "Nope",
+			want:   []string{"Response: Error\r\nMessage: Invalid/unknown command\r\nActionID: 1"},
+		},
+		{
+			name: "respond",
+			setup: func(srv *Server) {
+				srv.Respond("CoreStatus", Response("Success", "CoreCurrentCalls", "3"))
+			},
+			action: "corestatus",
+			want:   []string{"Response: Success\r\nCoreCurrentCalls: 3\r\nActionID: 1"},
+		},
+		{
+			name: "respond list",
+			setup: func(srv *Server) {
+				srv.RespondList("DeviceStateList", []Message{
+					Event("DeviceStateChange", "Device", "PJSIP/1001", "State", "NOT_INUSE"),
+				}, Event("DeviceStateListComplete"))
+			},
+			action: "DeviceStateList",
+			want: []string{
+				"Response: Success\r\nEventList: start\r\nMessage: Result will follow\r\nActionID: 1",
+				"Event: DeviceStateChange\r\nDevice: PJSIP/1001\r\nState: NOT_INUSE\r\nActionID: 1",
+				"Event: DeviceStateListComplete\r\nEventList: Complete\r\nListItems: 1\r\nActionID: 1",
+			},
+		},
+		{
+			name: "handle overrides builtin",
+			setup: func(srv *Server) {
+				srv.Handle("PING", func(c *Conn, a Action) {
+					c.Reply(a, Response("Error", "Message", a.Get("X")))
+				})
+			},
+			action: "Ping",
+			want:   []string{"Response: Error\r\nMessage: y\r\nActionID: 1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := NewServer()
+			defer srv.Close()
+			if tt.setup != nil {
+				tt.setup(srv)
+			}
+			conn, scanner := dial(t, srv)
+			defer conn.Close()
+
+			frames := exchange(t, conn, scanner, NewMessage("Action", tt.action, "ActionID", "1", "X", "y"), len(tt.want))
+			for i, want := range tt.want {
+				if !strings.HasPrefix(frames[i], want) {
+					t.Errorf("frame %d = %q, want prefix %q", i, frames[i], want)
+				}
+			}
+
+			received := srv.Received()
+			if len(received) != 1 || received[0].Name != tt.action || received[0].ActionID != "1" || received[0].Get("X") != "y" {
+				t.Errorf("Received() = %+v", received)
+			}
+		})
+	}
+}
+
+func TestServerInjectAndClose(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	conn, scanner := dial(t, srv)
+	defer conn.Close()
+
+	// 等待服务端登记连接
+	deadline := time.Now().Add(time.Second)
+	for srv.Connections() != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := srv.Connections(); n != 1 {
+		t.Fatalf("Connections() = %d", n)
+	}
+
+	srv.Inject(Event("FullyBooted", "Status", "Fully Booted"))
+	frame, err := scanner.Next()
+	if err != nil || frame != "Event: FullyBooted\r\nStatus: Fully Booted" {
+		t.Fatalf("injected frame = %q, %v", frame, err)
+	}
+
+	srv.CloseConnections()
+	if _, err := scanner.Next(); err == nil {
+		t.Errorf("Next() after CloseConnections succeeded")
+	}
+	if n := srv.Connections(); n != 0 {
+		t.Errorf("Connections() after CloseConnections = %d", n)
+	}
+}
@@ -0,0 +1,397 @@
+package parse
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Decode 宽松模式解码: 按 `ami:"Header-Name"` tag 将 data 写入 v 指向的结构体,
+// 忽略未知的头和格式错误的值. data 中以 \n 连接的值视为重复出现的头.
+//
+// 未设置 tag 的字段使用字段名匹配, 匹配时忽略大小写和 '-'. 支持的字段类型:
+//   - string, int*, uint*, float*
+//   - bool: yes/no, true/false, on/off, 1/0
+//   - time.Duration: 数字默认单位为秒, tag 选项 ",ms" ",us" 指定毫秒/微秒, 也接受 "1m30s"
+//   - time.Time: Asterisk 时间戳 "1701241602.714315" 或 "2006-01-02 15:04:05", "0" 为零值
+//   - slice: 重复出现的头, 每个值一个元素
+//   - map[string]string: "ChanVariable(name): value" 形式的头按 name 收集,
+//     或 "Variable: name=value" 形式的重复头按 '=' 拆分
+//
+// 非 slice 字段只取第一次出现的值. tag 选项 ",remain" 标记的 map[string]string
+// 字段收集所有未匹配的头, "-" 跳过字段.
+func Decode(data map[string]string, v interface{}) error {
+	return decode(mapHeaders(data), v, false)
+}
+
+// DecodeStrict 严格模式解码, 存在未知的头或格式错误的值时返回 *DecodeError,
+// 其余字段仍然会被写入
+func DecodeStrict(data map[string]string, v interface{}) error {
+	return decode(mapHeaders(data), v, true)
+}
+
+// DecodeMessage 与 Decode 相同, 按 message 中头的原始顺序解码
+func DecodeMessage(message *Message, v interface{}) error {
+	return decode(message.headers, v, false)
+}
+
+// DecodeMessageStrict 与 DecodeStrict 相同, 按 message 中头的原始顺序解码
+func DecodeMessageStrict(message *Message, v interface{}) error {
+	return decode(message.headers, v, true)
+}
+
+func mapHeaders(data map[string]string) []Header {
+	headers := make([]Header, 0, len(data))
+	for key, value := range data {
+		for _, line := range strings.Split(value, "\n") {
+			headers = append(headers, Header{Key: key, Value: line})
+		}
+	}
+	return headers
+}
+
+// FieldError 格式错误的头
+type FieldError struct {
+	Header string
+	Value  string
+	Err    error
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %q: %s", e.Header, e.Value, e.Err)
+}
+
+// DecodeError 严格模式下的解码错误
+type DecodeError struct {
+	// Unknown 没有对应字段的头
+	Unknown []string
+	// Malformed 无法转换的值
+	Malformed []FieldError
+}
+
+func (e *DecodeError) Error() string {
+	parts := make([]string, 0, 2)
+	if len(e.Unknown) > 0 {
+		parts = append(parts, "unknown headers: "+strings.Join(e.Unknown, ", "))
+	}
+	for _, field := range e.Malformed {
+		parts = append(parts, field.Error())
+	}
+	return "ami decode: " + strings.Join(parts, "; ")
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+
+	fieldCache sync.Map
+)
+
+type fieldInfo struct {
+	index []int
+	unit  time.Duration
+}
+
+type structFields struct {
+	byName map[string]fieldInfo
+	remain []int
+}
+
+func decode(headers []Header, v interface{}, strict bool) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("ami decode: target must be a non-nil pointer to struct")
+	}
+	rv = rv.Elem()
+	fields := cachedFields(rv.Type())
+
+	decodeErr := &DecodeError{}
+	assigned := make(map[string]bool)
+	for _, header := range headers {
+		key, value := header.Key, header.Value
+		name, arg := splitHeader(key)
+		fieldName := normalizeHeader(name)
+		field, ok := fields.byName[fieldName]
+		if ok && arg != "" && rv.FieldByIndex(field.index).Kind() != reflect.Map {
+			ok = false
+		}
+		if !ok {
+			fieldName = normalizeHeader(key)
+			field, ok = fields.byName[fieldName]
+		}
+		if !ok {
+			if fields.remain != nil {
+				remain := rv.FieldByIndex(fields.remain)
+				if remain.IsNil() {
+					remain.Set(reflect.MakeMap(remain.Type()))
+				}
+				if !remain.MapIndex(reflect.ValueOf(key)).IsValid() {
+					remain.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+				}
+				continue
+			}
+			decodeErr.Unknown = append(decodeErr.Unknown, key)
+			continue
+		}
+
+		fv := rv.FieldByIndex(field.index)
+		first := !assigned[fieldName]
+		assigned[fieldName] = true
+		if err := setField(fv, field, first, arg, value); err != nil {
+			decodeErr.Malformed = append(decodeErr.Malformed, FieldError{Header: key, Value: value, Err: err})
+		}
+	}
+
+	if !strict || (len(decodeErr.Unknown) == 0 && len(decodeErr.Malformed) == 0) {
+		return nil
+	}
+	sort.Strings(decodeErr.Unknown)
+	sort.SliceStable(decodeErr.Malformed, func(i, j int) bool {
+		return decodeErr.Malformed[i].Header < decodeErr.Malformed[j].Header
+	})
+	return decodeErr
+}
+
+func cachedFields(t reflect.Type) *structFields {
+	if cached, ok := fieldCache.Load(t); ok {
+		return cached.(*structFields)
+	}
+	fields := &structFields{byName: make(map[string]fieldInfo)}
+	collectFields(t, nil, fields)
+	fieldCache.Store(t, fields)
+	return fields
+}
+
+func collectFields(t reflect.Type, parent []int, fields *structFields) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		index := append(append([]int{}, parent...), i)
+		tag, hasTag := f.Tag.Lookup("ami")
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && !hasTag && f.Type.Kind() == reflect.Struct {
+			collectFields(f.Type, index, fields)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+		if name == "" {
+			name = f.Name
+		}
+		info := fieldInfo{index: index, unit: time.Second}
+		remain := false
+		for _, opt := range opts[1:] {
+			switch opt {
+			case "remain":
+				remain = true
+			case "ms":
+				info.unit = time.Millisecond
+			case "us":
+				info.unit = time.Microsecond
+			}
+		}
+		if remain {
+			fields.remain = index
+			continue
+		}
+		// 外层字段优先于嵌入结构体中的同名字段
+		key := normalizeHeader(name)
+		if existing, ok := fields.byName[key]; ok && len(existing.index) <= len(index) {
+			continue
+		}
+		fields.byName[key] = info
+	}
+}
+
+// splitHeader 拆分 "ChanVariable(name)" 为 "ChanVariable" 和 "name"
+func splitHeader(key string) (string, string) {
+	start := strings.IndexByte(key, '(')
+	if start <= 0 || !strings.HasSuffix(key, ")") {
+		return key, ""
+	}
+	return key[:start], key[start+1 : len(key)-1]
+}
+
+func normalizeHeader(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "-", ""))
+}
+
+// setField 写入一个头的值, first 表示该字段第一次出现
+func setField(field reflect.Value, info fieldInfo, first bool, arg, value string) error {
+	switch field.Kind() {
+	case reflect.Map:
+		if field.Type().Key().Kind() != reflect.String || field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map type %s", field.Type())
+		}
+		if first || field.IsNil() {
+			field.Set(reflect.MakeMap(field.Type()))
+		}
+		if arg == "" {
+			parts := strings.SplitN(value, "=", 2)
+			if len(parts) != 2 {
+				return errors.New("expected name=value")
+			}
+			arg, value = parts[0], parts[1]
+		}
+		field.SetMapIndex(reflect.ValueOf(arg), reflect.ValueOf(value))
+		return nil
+	case reflect.Slice:
+		if first {
+			field.Set(reflect.MakeSlice(field.Type(), 0, 1))
+		}
+		elem := reflect.New(field.Type().Elem()).Elem()
+		if err := setScalar(elem, info, value); err != nil {
+			return err
+		}
+		field.Set(reflect.Append(field, elem))
+		return nil
+	default:
+		if !first {
+			return nil
+		}
+		return setScalar(field, info, value)
+	}
+}
+
+func setScalar(field reflect.Value, info fieldInfo, value string) error {
+	value = strings.TrimSpace(value)
+	switch {
+	case field.Type() == durationType:
+		d, err := parseDuration(value, info.unit)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	case field.Type() == timeType:
+		t, err := parseTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if value == "" {
+			field.SetInt(0)
+			return nil
+		}
+		i, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if value == "" {
+			field.SetUint(0)
+			return nil
+		}
+		u, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if value == "" {
+			field.SetFloat(0)
+			return nil
+		}
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// ParseBool 解析 Asterisk 布尔值 yes/no, true/false, on/off, 1/0, 空值为 false
+func ParseBool(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "true", "on", "1", "y":
+		return true, nil
+	case "no", "false", "off", "0", "n", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean %q", value)
+}
+
+func parseDuration(value string, unit time.Duration) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(f * float64(unit)), nil
+	}
+	return time.ParseDuration(value)
+}
+
+func parseTime(value string) (time.Time, error) {
+	// Asterisk 以 0 表示从未发生, 如 QueueMember 的 LastCall
+	if value == "" || value == "0" {
+		return time.Time{}, nil
+	}
+	if sec, frac, ok := splitTimestamp(value); ok {
+		return time.Unix(sec, frac), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// splitTimestamp 解析 "1701241602.714315" 为秒和纳秒, 避免浮点误差
+func splitTimestamp(value string) (int64, int64, bool) {
+	secPart, fracPart := value, ""
+	if dot := strings.IndexByte(value, '.'); dot >= 0 {
+		secPart, fracPart = value[:dot], value[dot+1:]
+	}
+	sec, err := strconv.ParseInt(secPart, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	if len(fracPart) > 9 {
+		fracPart = fracPart[:9]
+	}
+	var nsec int64
+	if fracPart != "" {
+		frac, err := strconv.ParseInt(fracPart, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		nsec = frac * int64(pow10(9-len(fracPart)))
+	}
+	return sec, nsec, true
+}
+
+func pow10(n int) int {
+	result := 1
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
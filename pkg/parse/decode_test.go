@@ -0,0 +1,229 @@
+package parse
+
+import (
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type decodeInner struct {
+	Name    string
+	Channel string
+}
+
+type decodeOuter struct {
+	decodeInner
+	Name string `ami:"Name"`
+}
+
+type decodeVariables struct {
+	ChanVariable map[string]string
+	Variable     map[string]string
+}
+
+type decodeRemain struct {
+	Event string
+	Rest  map[string]string `ami:",remain"`
+}
+
+type decodeDurations struct {
+	RoundTrip time.Duration `ami:"RoundtripUsec,us"`
+	Holdtime  time.Duration `ami:",ms"`
+	Wait      time.Duration
+	TalkTime  time.Duration
+}
+
+type decodeTimes struct {
+	Timestamp time.Time
+	LastCall  time.Time
+	Created   time.Time
+}
+
+type decodeRepeated struct {
+	Codec []string
+	Port  []int
+	Name  string
+}
+
+type decodeTypes struct {
+	Exten    string `ami:"Exten"`
+	Priority int
+	Paused   bool
+	Perf     float64 `ami:"ServicelevelPerf"`
+	Skipped  string  `ami:"-"`
+}
+
+func TestDecodeMessage(t *testing.T) {
+	created, _ := time.ParseInLocation("2006-01-02 15:04:05", "2023-11-29 10:00:00", time.Local)
+	tests := []struct {
+		name   string
+		frame  string
+		target interface{}
+		want   interface{}
+	}{
+		{
+			name:   "outer field wins over embedded",
+			frame:  "Event: X\r\nName: outer\r\nChannel: PJSIP/1001-1",
+			target: &decodeOuter{},
+			want:   &decodeOuter{decodeInner: decodeInner{Channel: "PJSIP/1001-1"}, Name: "outer"},
+		},
+		{
+			name:   "ChanVariable(name)",
+			frame:  "Event: X\r\nChanVariable(FOO): 1\r\nChanVariable(BAR): x=y",
+			target: &decodeVariables{},
+			want:   &decodeVariables{ChanVariable: map[string]string{"FOO": "1", "BAR": "x=y"}},
+		},
+		{
+			name:   "Variable: a=b",
+			frame:  "Event: X\r\nVariable: a=b\r\nVariable: c=d=e",
+			target: &decodeVariables{},
+			want:   &decodeVariables{Variable: map[string]string{"a": "b", "c": "d=e"}},
+		},
+		{
+			name:   "remain keeps first value of unmatched headers",
+			frame:  "Event: X\r\nFoo: 1\r\nBar-Baz: 2\r\nFoo: 3",
+			target: &decodeRemain{},
+			want:   &decodeRemain{Event: "X", Rest: map[string]string{"Foo": "1", "Bar-Baz": "2"}},
+		},
+		{
+			name:   "duration units",
+			frame:  "Event: X\r\nRoundtripUsec: 1500\r\nHoldtime: 250\r\nWait: 3\r\nTalk-Time: 1m30s",
+			target: &decodeDurations{},
+			want: &decodeDurations{
+				RoundTrip: 1500 * time.Microsecond,
+				Holdtime:  250 * time.Millisecond,
+				Wait:      3 * time.Second,
+				TalkTime:  90 * time.Second,
+			},
+		},
+		{
+			name:   "asterisk timestamps",
+			frame:  "Event: X\r\nTimestamp: 1701241602.714315\r\nLastCall: 0\r\nCreated: 2023-11-29 10:00:00",
+			target: &decodeTimes{},
+			want:   &decodeTimes{Timestamp: time.Unix(1701241602, 714315000), Created: created},
+		},
+		{
+			name:   "repeated headers into slices",
+			frame:  "Event: X\r\nCodec: ulaw\r\nPort: 5060\r\nCodec: alaw\r\nPort: 5061\r\nName: a\r\nName: b",
+			target: &decodeRepeated{},
+			want:   &decodeRepeated{Codec: []string{"ulaw", "alaw"}, Port: []int{5060, 5061}, Name: "a"},
+		},
+		{
+			name:   "scalar types and case-insensitive names",
+			frame:  "Event: X\r\nexten: 1001\r\nPRIORITY: 2\r\nPaused: yes\r\nServicelevelPerf: 87.5\r\nSkipped: x",
+			target: &decodeTypes{},
+			want:   &decodeTypes{Exten: "1001", Priority: 2, Paused: true, Perf: 87.5},
+		},
+		{
+			name:   "lax mode ignores malformed values",
+			frame:  "Event: X\r\nPriority: two\r\nPaused: maybe\r\nExten: 1001",
+			target: &decodeTypes{},
+			want:   &decodeTypes{Exten: "1001"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := DecodeMessage(NewEvent(tt.frame).Message, tt.target); err != nil {
+				t.Fatalf("DecodeMessage() err = %v", err)
+			}
+			if !reflect.DeepEqual(tt.target, tt.want) {
+				t.Errorf("DecodeMessage() = %+v, want %+v", tt.target, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeMap(t *testing.T) {
+	// Data 中重复的头以 \n 连接
+	data := NewEvent("Event: X\r\nCodec: ulaw\r\nCodec: alaw\r\nPort: 5060\r\nName: a").Data
+	var got decodeRepeated
+	if err := Decode(data, &got); err != nil {
+		t.Fatalf("Decode() err = %v", err)
+	}
+	want := decodeRepeated{Codec: []string{"ulaw", "alaw"}, Port: []int{5060}, Name: "a"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Decode() = %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		name   string
+		frame  string
+		target interface{}
+		want   interface{}
+		err    *DecodeError
+	}{
+		{
+			name:   "clean",
+			frame:  "Exten: 1001\r\nPriority: 1",
+			target: &decodeTypes{},
+			want:   &decodeTypes{Exten: "1001", Priority: 1},
+		},
+		{
+			name:   "unknown headers sorted",
+			frame:  "Event: X\r\nExten: 1001\r\nZeta: 1\r\nAlpha: 2",
+			target: &decodeTypes{},
+			want:   &decodeTypes{Exten: "1001"},
+			err:    &DecodeError{Unknown: []string{"Alpha", "Event", "Zeta"}},
+		},
+		{
+			name:   "malformed values",
+			frame:  "Priority: two\r\nPaused: maybe\r\nExten: 1001",
+			target: &decodeTypes{},
+			want:   &decodeTypes{Exten: "1001"},
+			err: &DecodeError{Malformed: []FieldError{
+				{Header: "Paused", Value: "maybe", Err: errors.New(`invalid boolean "maybe"`)},
+				{Header: "Priority", Value: "two", Err: &strconv.NumError{Func: "ParseInt", Num: "two", Err: strconv.ErrSyntax}},
+			}},
+		},
+		{
+			name:   "unknown and malformed",
+			frame:  "Event: X\r\nTimestamp: soon",
+			target: &decodeTimes{},
+			want:   &decodeTimes{},
+			err: &DecodeError{
+				Unknown:   []string{"Event"},
+				Malformed: []FieldError{{Header: "Timestamp", Value: "soon", Err: errors.New(`invalid time "soon"`)}},
+			},
+		},
+		{
+			name:   "malformed Variable",
+			frame:  "Variable: novalue",
+			target: &decodeVariables{},
+			want:   &decodeVariables{Variable: map[string]string{}},
+			err:    &DecodeError{Malformed: []FieldError{{Header: "Variable", Value: "novalue", Err: errors.New("expected name=value")}}},
+		},
+		{
+			name:   "remain accepts unknown headers",
+			frame:  "Event: X\r\nFoo: 1",
+			target: &decodeRemain{},
+			want:   &decodeRemain{Event: "X", Rest: map[string]string{"Foo": "1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := DecodeMessageStrict(NewEvent(tt.frame).Message, tt.target)
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("DecodeMessageStrict() err = %v", err)
+				}
+			} else {
+				var decodeErr *DecodeError
+				if !errors.As(err, &decodeErr) {
+					t.Fatalf("DecodeMessageStrict() err = %v, want *DecodeError", err)
+				}
+				if err.Error() != tt.err.Error() || !reflect.DeepEqual(decodeErr.Unknown, tt.err.Unknown) {
+					t.Errorf("DecodeMessageStrict() err = %v, want %v", err, tt.err)
+				}
+			}
+			if !reflect.DeepEqual(tt.target, tt.want) {
+				t.Errorf("DecodeMessageStrict() = %+v, want %+v", tt.target, tt.want)
+			}
+		})
+	}
+}
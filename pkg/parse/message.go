@@ -9,10 +9,19 @@ import (
 	"github.com/tqcenglish/amigo-go/utils"
 )
 
+//Header 一个 AMI 头, 保留原始的 key
+type Header struct {
+	Key   string
+	Value string
+}
+
 //Message ami 消息
 type Message struct {
 	lines []string
 	// variable map[string]string
+	// headers 按收到顺序保存, 允许重复
+	headers []Header
+	// Data 兼容视图: key 去掉 '-', 重复的 key 以 \n 连接
 	Data map[string]string
 	sync.RWMutex
 }
@@ -28,21 +37,81 @@ func (message *Message) unMarshall(data string) {
 	//     }
 	// }
 	message.lines = strings.Split(data, utils.EOL)
+	message.headers = make([]Header, 0, len(message.lines))
+	// Command 输出不是头, 不记录到 headers 和 Data
+	follows := message.lines[0] == ResponseFollows
 	for i := 0; i < len(message.lines); i++ {
-		parts := strings.Split(message.lines[i], ":")
+		if follows && i > 0 && !IsCommandHeader(message.lines[i]) {
+			break
+		}
+		parts := strings.SplitN(message.lines[i], ":", 2)
+		value := ""
 		if len(parts) <= 1 {
 			log.Errorf("Error message foramt")
+		} else {
+			value = strings.TrimSpace(parts[1])
+			message.headers = append(message.headers, Header{Key: strings.TrimSpace(parts[0]), Value: value})
 		}
 		key := strings.ReplaceAll(strings.TrimSpace(parts[0]), "-", "")
-		value := strings.Join(parts[1:], ":")
 		// 存在相同 key, 累加到 value
 		// message.Data[key] = strings.TrimSpace(value)
 		if oldValue, ok := message.Data[key]; ok {
-			message.Data[key] = fmt.Sprintf("%s\n%s", oldValue, strings.TrimSpace(value))
+			message.Data[key] = fmt.Sprintf("%s\n%s", oldValue, value)
 		} else {
-			message.Data[key] = strings.TrimSpace(value)
+			message.Data[key] = value
+		}
+	}
+}
+
+//IsCommandHeader 判断 "Response: Follows" 之后的行是否为头, 其余行为 Command 输出
+func IsCommandHeader(line string) bool {
+	for _, header := range []string{"Privilege:", "ActionID:", "Message:"} {
+		if strings.HasPrefix(line, header) {
+			return true
+		}
+	}
+	return false
+}
+
+//Get 返回第一个名为 key 的头, 不区分大小写
+func (message *Message) Get(key string) string {
+	for _, header := range message.headers {
+		if strings.EqualFold(header.Key, key) {
+			return header.Value
 		}
 	}
+	return ""
+}
+
+//Lookup 与 Get 相同, 第二个返回值表示头是否存在
+func (message *Message) Lookup(key string) (string, bool) {
+	for _, header := range message.headers {
+		if strings.EqualFold(header.Key, key) {
+			return header.Value, true
+		}
+	}
+	return "", false
+}
+
+//GetAll 按顺序返回所有名为 key 的头, 不区分大小写
+func (message *Message) GetAll(key string) []string {
+	var values []string
+	for _, header := range message.headers {
+		if strings.EqualFold(header.Key, key) {
+			values = append(values, header.Value)
+		}
+	}
+	return values
+}
+
+//Headers 按收到顺序返回所有头的副本
+func (message *Message) Headers() []Header {
+	return append([]Header(nil), message.headers...)
+}
+
+//Lines 返回原始行
+func (message *Message) Lines() []string {
+	return append([]string(nil), message.lines...)
 }
 
 func (message *Message) String() {
@@ -0,0 +1,50 @@
+package parse
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMessageUnMarshall(t *testing.T) {
+	tests := []struct {
+		name    string
+		frame   string
+		data    map[string]string
+		headers []Header
+	}{
+		{
+			name:  "repeated headers",
+			frame: "Response: Success\r\nActionID: 1\r\nOutput: a\r\nOutput: b\r\nCaller-ID: 1001",
+			data:  map[string]string{"Response": "Success", "ActionID": "1", "Output": "a\nb", "CallerID": "1001"},
+			headers: []Header{
+				{"Response", "Success"}, {"ActionID", "1"}, {"Output", "a"}, {"Output", "b"}, {"Caller-ID", "1001"},
+			},
+		},
+		{
+			name:  "follows body is not headers",
+			frame: "Response: Follows\r\nPrivilege: Command\r\nActionID: 1\r\nUptime: 5 minutes\r\n\r\nno colon here\r\n--END COMMAND--",
+			data:  map[string]string{"Response": "Follows", "Privilege": "Command", "ActionID": "1"},
+			headers: []Header{
+				{"Response", "Follows"}, {"Privilege", "Command"}, {"ActionID", "1"},
+			},
+		},
+		{
+			name:    "follows without headers",
+			frame:   "Response: Follows\r\noutput--END COMMAND--",
+			data:    map[string]string{"Response": "Follows"},
+			headers: []Header{{"Response", "Follows"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := NewResponse(tt.frame)
+			if !reflect.DeepEqual(res.Data, tt.data) {
+				t.Errorf("Data = %q, want %q", res.Data, tt.data)
+			}
+			if !reflect.DeepEqual(res.Headers(), tt.headers) {
+				t.Errorf("Headers() = %q, want %q", res.Headers(), tt.headers)
+			}
+		})
+	}
+}
@@ -1,12 +1,18 @@
 package parse
 
-import "fmt"
+import (
+	"fmt"
+	"sync"
+)
 
 //Response 命令响应
 type Response struct {
 	*Message
 	Events   []Event
 	Complete chan struct{}
+
+	finish *sync.Once
+	err    error
 }
 
 //NewResponse 新建事件
@@ -17,7 +23,8 @@ func NewResponse(data string) *Response {
 	response := &Response{
 		Message:  messages,
 		Events:   make([]Event, 0),
-		Complete: make(chan struct{}, 1),
+		Complete: make(chan struct{}),
+		finish:   &sync.Once{},
 	}
 	if data != "" {
 		response.unMarshall(data)
@@ -25,6 +32,37 @@ func NewResponse(data string) *Response {
 	return response
 }
 
+//Update 以收到的响应内容替换当前内容
+func (res *Response) Update(response *Response) {
+	res.Lock()
+	res.lines = response.lines
+	res.headers = response.headers
+	res.Data = response.Data
+	res.Unlock()
+}
+
+//Finish 标记响应完成并关闭 Complete, 重复调用无影响
+func (res *Response) Finish() {
+	res.Fail(nil)
+}
+
+//Fail 以错误结束响应并关闭 Complete, 重复调用无影响
+func (res *Response) Fail(err error) {
+	res.finish.Do(func() {
+		res.Lock()
+		res.err = err
+		res.Unlock()
+		close(res.Complete)
+	})
+}
+
+//Err 返回结束响应时的错误
+func (res *Response) Err() error {
+	res.RLock()
+	defer res.RUnlock()
+	return res.err
+}
+
 //String 定义 toString
 func (res Response) String() string {
 	return fmt.Sprintf("{Response: %s, ActionID: %s, Message: %s, Event:%+v}", res.Data["Response"], res.Data["ActionID"], res.Data["Message"], res.Events)
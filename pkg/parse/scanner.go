@@ -0,0 +1,128 @@
+package parse
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+//DefaultMaxFrameSize 单帧默认最大长度
+const DefaultMaxFrameSize = 1 << 20
+
+const (
+	//ResponseFollows Asterisk 13 及以前 Command 响应的第一行
+	ResponseFollows = "Response: Follows"
+	//CommandEnd Asterisk 13 及以前 Command 输出的结束标记
+	CommandEnd = "--END COMMAND--"
+)
+
+//ErrFrameTooLarge 帧超过最大长度, 该帧剩余部分已丢弃
+var ErrFrameTooLarge = errors.New("ami frame too large")
+
+//FrameScanner 从连接中读取以空行结束的 AMI 帧
+//兼容 \r\n 与 \n 换行, 跳过帧之间多余的空行
+type FrameScanner struct {
+	reader       *bufio.Reader
+	maxFrameSize int
+
+	line  []byte
+	frame bytes.Buffer
+}
+
+//NewFrameScanner 新建 FrameScanner, maxFrameSize <= 0 时使用 DefaultMaxFrameSize
+func NewFrameScanner(r io.Reader, maxFrameSize int) *FrameScanner {
+	if maxFrameSize <= 0 {
+		maxFrameSize = DefaultMaxFrameSize
+	}
+	return &FrameScanner{
+		reader:       bufio.NewReaderSize(r, 4096),
+		maxFrameSize: maxFrameSize,
+	}
+}
+
+//ReadLine 读取一行(不含换行符), 用于读取欢迎信息
+func (s *FrameScanner) ReadLine() (string, error) {
+	line, err := s.readLine()
+	return string(line), err
+}
+
+//Next 返回下一帧, 各行以 utils.EOL 连接, 不含结尾空行
+//"Response: Follows" 开头的 Command 输出(Asterisk 13 及以前)读取到 CommandEnd 为止, 输出中的空行会保留
+func (s *FrameScanner) Next() (string, error) {
+	s.frame.Reset()
+	tooLarge := false
+	follows := false
+	for {
+		line, err := s.readLine()
+		if err == ErrFrameTooLarge {
+			tooLarge = true
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if len(line) == 0 && !follows {
+			if tooLarge {
+				return "", ErrFrameTooLarge
+			}
+			if s.frame.Len() == 0 {
+				continue
+			}
+			return s.frame.String(), nil
+		}
+		if !tooLarge && s.frame.Len()+len(utils.EOL)+len(line) > s.maxFrameSize {
+			tooLarge = true
+			s.frame.Reset()
+		}
+		if tooLarge {
+			// 丢弃超长帧的剩余部分, 但仍需识别 Command 输出的结束标记, 否则之后的帧都会被当作输出
+			if follows && bytes.HasSuffix(line, []byte(CommandEnd)) {
+				follows = false
+			}
+			continue
+		}
+		if s.frame.Len() > 0 {
+			s.frame.WriteString(utils.EOL)
+		} else {
+			follows = bytes.Equal(line, []byte(ResponseFollows))
+		}
+		s.frame.Write(line)
+		if follows && bytes.HasSuffix(line, []byte(CommandEnd)) {
+			follows = false
+		}
+	}
+}
+
+//readLine 读取一行并去掉结尾的 \r\n 或 \n, 超长的行返回 ErrFrameTooLarge
+func (s *FrameScanner) readLine() ([]byte, error) {
+	s.line = s.line[:0]
+	tooLarge := false
+	for {
+		chunk, err := s.reader.ReadSlice('\n')
+		if !tooLarge {
+			if len(s.line)+len(chunk) > s.maxFrameSize {
+				tooLarge = true
+				s.line = s.line[:0]
+			} else {
+				s.line = append(s.line, chunk...)
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		break
+	}
+	if tooLarge {
+		return nil, ErrFrameTooLarge
+	}
+
+	line := bytes.TrimSuffix(s.line, []byte("\n"))
+	return bytes.TrimSuffix(line, []byte("\r")), nil
+}
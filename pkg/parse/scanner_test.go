@@ -0,0 +1,142 @@
+package parse
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type scanResult struct {
+	frame string
+	err   error
+}
+
+func scanAll(input string, maxFrameSize int) []scanResult {
+	scanner := NewFrameScanner(strings.NewReader(input), maxFrameSize)
+	var results []scanResult
+	for {
+		frame, err := scanner.Next()
+		results = append(results, scanResult{frame, err})
+		if err == io.EOF {
+			return results
+		}
+	}
+}
+
+func TestFrameScannerNext(t *testing.T) {
+	long := strings.Repeat("x", 200)
+	tests := []struct {
+		name  string
+		input string
+		max   int
+		want  []scanResult
+	}{
+		{
+			name:  "crlf",
+			input: "Event: A\r\nKey: 1\r\n\r\nEvent: B\r\n\r\n",
+			want: []scanResult{
+				{"Event: A\r\nKey: 1", nil},
+				{"Event: B", nil},
+				{"", io.EOF},
+			},
+		},
+		{
+			name:  "lf only",
+			input: "Event: A\nKey: 1\n\nEvent: B\n\n",
+			want: []scanResult{
+				{"Event: A\r\nKey: 1", nil},
+				{"Event: B", nil},
+				{"", io.EOF},
+			},
+		},
+		{
+			name:  "leading blank lines",
+			input: "\r\n\n\r\nEvent: A\r\n\r\n\r\n\r\nEvent: B\r\n\r\n",
+			want: []scanResult{
+				{"Event: A", nil},
+				{"Event: B", nil},
+				{"", io.EOF},
+			},
+		},
+		{
+			name:  "oversized frame",
+			input: "Event: A\r\nData: " + long + "\r\n\r\nEvent: B\r\n\r\n",
+			max:   100,
+			want: []scanResult{
+				{"", ErrFrameTooLarge},
+				{"Event: B", nil},
+				{"", io.EOF},
+			},
+		},
+		{
+			name:  "oversized frame by accumulated lines",
+			input: "Event: A\r\n" + strings.Repeat("Key: 0123456789\r\n", 10) + "\r\nEvent: B\r\n\r\n",
+			max:   100,
+			want: []scanResult{
+				{"", ErrFrameTooLarge},
+				{"Event: B", nil},
+				{"", io.EOF},
+			},
+		},
+		{
+			name:  "follows keeps blank lines",
+			input: "Response: Follows\r\nPrivilege: Command\r\nline 1\r\n\r\nline 2\r\n--END COMMAND--\r\n\r\nEvent: B\r\n\r\n",
+			want: []scanResult{
+				{"Response: Follows\r\nPrivilege: Command\r\nline 1\r\n\r\nline 2\r\n--END COMMAND--", nil},
+				{"Event: B", nil},
+				{"", io.EOF},
+			},
+		},
+		{
+			name:  "oversized follows",
+			input: "Response: Follows\r\nPrivilege: Command\r\n" + long + "\r\n\r\n--END COMMAND--\r\n\r\nEvent: A\r\n\r\n",
+			max:   100,
+			want: []scanResult{
+				{"", ErrFrameTooLarge},
+				{"Event: A", nil},
+				{"", io.EOF},
+			},
+		},
+		{
+			name:  "oversized follows by accumulated lines",
+			input: "Response: Follows\r\n" + strings.Repeat("0123456789\r\n", 20) + "--END COMMAND--\r\n\r\nEvent: A\r\n\r\n",
+			max:   100,
+			want: []scanResult{
+				{"", ErrFrameTooLarge},
+				{"Event: A", nil},
+				{"", io.EOF},
+			},
+		},
+		{
+			name:  "follows end on last output line",
+			input: "Response: Follows\r\noutput--END COMMAND--\r\n\r\nEvent: A\r\n\r\n",
+			want: []scanResult{
+				{"Response: Follows\r\noutput--END COMMAND--", nil},
+				{"Event: A", nil},
+				{"", io.EOF},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scanAll(tt.input, tt.max)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Next() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFrameScannerReadLine(t *testing.T) {
+	scanner := NewFrameScanner(strings.NewReader("Asterisk Call Manager/5.0.1\r\nEvent: A\r\n\r\n"), 0)
+	banner, err := scanner.ReadLine()
+	if err != nil || banner != "Asterisk Call Manager/5.0.1" {
+		t.Fatalf("ReadLine() = %q, %v", banner, err)
+	}
+	frame, err := scanner.Next()
+	if err != nil || frame != "Event: A" {
+		t.Fatalf("Next() = %q, %v", frame, err)
+	}
+}
@@ -0,0 +1,114 @@
+// Package record 以 JSON lines 记录 AMI 会话的收发帧, 并按原始节奏回放
+package record
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Direction 帧的方向
+type Direction string
+
+const (
+	// In Asterisk 发给客户端的帧
+	In Direction = "in"
+	// Out 客户端发给 Asterisk 的帧
+	Out Direction = "out"
+)
+
+// Frame 一条记录, Data 为不含结尾空行的 AMI 消息
+type Frame struct {
+	Time      time.Time `json:"time"`
+	Direction Direction `json:"dir"`
+	Data      string    `json:"data"`
+}
+
+// Recorder 将帧以 JSON lines 写入 io.Writer, 可并发调用
+type Recorder struct {
+	mutex   sync.Mutex
+	writer  io.Writer
+	encoder *json.Encoder
+}
+
+// NewRecorder 新建 Recorder
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		writer:  w,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// Record 写入一帧
+func (r *Recorder) Record(dir Direction, data string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.encoder.Encode(Frame{Time: time.Now(), Direction: dir, Data: data})
+}
+
+// Close 关闭底层 writer(如果实现了 io.Closer)
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if closer, ok := r.writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Reader 顺序读取记录
+type Reader struct {
+	decoder *json.Decoder
+}
+
+// NewReader 新建 Reader
+func NewReader(r io.Reader) *Reader {
+	return &Reader{decoder: json.NewDecoder(r)}
+}
+
+// Next 返回下一帧, 结束时返回 io.EOF
+func (r *Reader) Next() (Frame, error) {
+	var frame Frame
+	err := r.decoder.Decode(&frame)
+	return frame, err
+}
+
+// Replay 依次将 dir 方向的帧交给 fn, 帧间按原始间隔除以 speed 等待
+// speed 为 1 按原始速度, 2 为两倍速, <= 0 不等待
+func Replay(ctx context.Context, r io.Reader, dir Direction, speed float64, fn func(Frame) error) error {
+	reader := NewReader(r)
+	var last time.Time
+	for {
+		frame, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if frame.Direction != dir {
+			continue
+		}
+
+		if speed > 0 && !last.IsZero() && frame.Time.After(last) {
+			wait := time.Duration(float64(frame.Time.Sub(last)) / speed)
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+		last = frame.Time
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(frame); err != nil {
+			return err
+		}
+	}
+}
@@ -7,6 +7,10 @@ const (
 	Connect_Password_Error   ConnectStatus = 1
 	Connect_Network_Error    ConnectStatus = 2
 	Disconnect_Network_Error ConnectStatus = 3
+	// Reconnecting 等待重连, payload: attempt int, delay time.Duration
+	Reconnecting ConnectStatus = 4
+	// Reconnect_Give_Up 重连策略放弃重连, payload: attempts int
+	Reconnect_Give_Up ConnectStatus = 5
 )
 
 func (status ConnectStatus) String() string {
@@ -19,6 +23,10 @@ func (status ConnectStatus) String() string {
 		return "Network Error"
 	case Disconnect_Network_Error:
 		return "Network Disconnect Error"
+	case Reconnecting:
+		return "Reconnecting"
+	case Reconnect_Give_Up:
+		return "Reconnect Give Up"
 	default:
 		return "Unknown Error"
 	}
@@ -0,0 +1,657 @@
+package amigo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// MemberStatus 队列成员的设备状态, 即 QueueMember 事件的 Status
+type MemberStatus int
+
+// 与 Asterisk 的 AST_DEVICE_* 取值对应
+const (
+	MemberUnknown MemberStatus = iota
+	MemberNotInUse
+	MemberInUse
+	MemberBusy
+	MemberInvalid
+	MemberUnavailable
+	MemberRinging
+	MemberRingInUse
+	MemberOnHold
+)
+
+func (s MemberStatus) String() string {
+	switch s {
+	case MemberUnknown:
+		return "Unknown"
+	case MemberNotInUse:
+		return "NotInUse"
+	case MemberInUse:
+		return "InUse"
+	case MemberBusy:
+		return "Busy"
+	case MemberInvalid:
+		return "Invalid"
+	case MemberUnavailable:
+		return "Unavailable"
+	case MemberRinging:
+		return "Ringing"
+	case MemberRingInUse:
+		return "RingInUse"
+	case MemberOnHold:
+		return "OnHold"
+	}
+	return fmt.Sprintf("Unknown(%d)", int(s))
+}
+
+// QueueMember 队列成员
+type QueueMember struct {
+	Interface      string
+	Name           string
+	StateInterface string
+	Membership     string
+	Penalty        int
+	CallsTaken     int
+	LastCall       time.Time
+	LastPause      time.Time
+	LoginTime      time.Time
+	InCall         bool
+	Status         MemberStatus
+	Paused         bool
+	PausedReason   string
+	Ringinuse      bool
+	Wrapuptime     int
+	// Ringing 正在呼叫该成员的来电 Uniqueid, 由 AgentCalled 设置
+	Ringing string
+	// Connected 该成员正在接听的来电 Uniqueid, 由 AgentConnect 设置
+	Connected string
+}
+
+// Available 未暂停且空闲的成员可以接听来电
+func (m *QueueMember) Available() bool {
+	return !m.Paused && m.Status == MemberNotInUse && m.Ringing == "" && m.Connected == ""
+}
+
+// QueueCaller 排队中的来电
+type QueueCaller struct {
+	Uniqueid     string
+	Channel      string
+	CallerIDNum  string
+	CallerIDName string
+	Position     int
+	Joined       time.Time
+}
+
+// Wait 返回已等待时间
+func (c *QueueCaller) Wait() time.Duration {
+	return time.Since(c.Joined)
+}
+
+// Queue 队列快照
+type Queue struct {
+	Name              string
+	Strategy          string
+	Max               int
+	Holdtime          int
+	TalkTime          int
+	Completed         int
+	Abandoned         int
+	ServiceLevel      int
+	ServicelevelPerf  float64
+	ServicelevelPerf2 float64
+	Weight            int
+	// Members 按 Interface 排序
+	Members []QueueMember
+	// Callers 按排队位置排序
+	Callers []QueueCaller
+}
+
+// LongestWait 返回等待最久的来电的等待时间
+func (q *Queue) LongestWait() time.Duration {
+	var longest time.Duration
+	for i := range q.Callers {
+		if wait := q.Callers[i].Wait(); wait > longest {
+			longest = wait
+		}
+	}
+	return longest
+}
+
+// Available 返回可以接听来电的成员数
+func (q *Queue) Available() int {
+	available := 0
+	for i := range q.Members {
+		if q.Members[i].Available() {
+			available++
+		}
+	}
+	return available
+}
+
+// Paused 返回暂停的成员数
+func (q *Queue) Paused() int {
+	paused := 0
+	for i := range q.Members {
+		if q.Members[i].Paused {
+			paused++
+		}
+	}
+	return paused
+}
+
+type trackedQueue struct {
+	Queue
+	members map[string]*QueueMember
+	callers map[string]*QueueCaller
+}
+
+func newTrackedQueue(name string) *trackedQueue {
+	return &trackedQueue{
+		Queue:   Queue{Name: name},
+		members: make(map[string]*QueueMember),
+		callers: make(map[string]*QueueCaller),
+	}
+}
+
+func (q *trackedQueue) snapshot() Queue {
+	queue := q.Queue
+	queue.Members = make([]QueueMember, 0, len(q.members))
+	for _, member := range q.members {
+		queue.Members = append(queue.Members, *member)
+	}
+	sort.Slice(queue.Members, func(i, j int) bool {
+		return queue.Members[i].Interface < queue.Members[j].Interface
+	})
+	queue.Callers = make([]QueueCaller, 0, len(q.callers))
+	for _, caller := range q.callers {
+		queue.Callers = append(queue.Callers, *caller)
+	}
+	sort.Slice(queue.Callers, func(i, j int) bool {
+		return queue.Callers[i].Position < queue.Callers[j].Position
+	})
+	return queue
+}
+
+func (q *trackedQueue) member(iface string) *QueueMember {
+	member, ok := q.members[iface]
+	if !ok {
+		member = &QueueMember{Interface: iface}
+		q.members[iface] = member
+	}
+	return member
+}
+
+// renumber 来电离开后按原顺序重新编号
+func (q *trackedQueue) renumber() {
+	callers := make([]*QueueCaller, 0, len(q.callers))
+	for _, caller := range q.callers {
+		callers = append(callers, caller)
+	}
+	sort.Slice(callers, func(i, j int) bool {
+		return callers[i].Position < callers[j].Position
+	})
+	for i, caller := range callers {
+		caller.Position = i + 1
+	}
+}
+
+func applyMemberHeader(member *QueueMember, h *QueueMemberHeader) {
+	member.Name = h.MemberName
+	member.StateInterface = h.StateInterface
+	member.Membership = h.Membership
+	member.Penalty = h.Penalty
+	member.CallsTaken = h.CallsTaken
+	member.LastCall = h.LastCall
+	member.LastPause = h.LastPause
+	member.LoginTime = h.LoginTime
+	member.InCall = h.InCall
+	member.Status = MemberStatus(h.Status)
+	member.Paused = h.Paused
+	member.PausedReason = h.PausedReason
+	member.Ringinuse = h.Ringinuse
+	member.Wrapuptime = h.Wrapuptime
+}
+
+// QueueChange Queues 的变更通知
+type QueueChange struct {
+	Queue Queue
+	// Event 引起变更的事件, 由 QueueStatus 同步产生的变更为 nil
+	Event Event
+}
+
+var queueEvents = []string{
+	"QueueMemberAdded", "QueueMemberRemoved", "QueueMemberPause", "QueueMemberStatus",
+	"QueueMemberPenalty", "QueueMemberRinginuse",
+	"QueueCallerJoin", "QueueCallerLeave", "QueueCallerAbandon",
+	"AgentCalled", "AgentConnect", "AgentComplete", "AgentRingNoAnswer",
+}
+
+// Queues 维护队列、成员和排队来电
+// 每次登录后通过 QueueStatus 重新同步, 之后由队列事件增量更新
+type Queues struct {
+	tracker
+	queues map[string]*trackedQueue
+}
+
+// NewQueues 新建 Queues 并开始跟踪, 已登录时立即同步
+func NewQueues(a *Amigo) *Queues {
+	q := &Queues{queues: make(map[string]*trackedQueue)}
+	q.start(a, "queues", queueEvents, q.handle, q.seed)
+	return q
+}
+
+// Close 停止跟踪和重新同步, Get/Queues 仍返回最后的队列状态
+func (q *Queues) Close() {
+	q.close()
+}
+
+// Get 按名称查找队列
+func (q *Queues) Get(name string) (Queue, bool) {
+	q.mutex.RLock()
+	defer q.mutex.RUnlock()
+	if queue, ok := q.queues[name]; ok {
+		return queue.snapshot(), true
+	}
+	return Queue{}, false
+}
+
+// Queues 返回全部队列, 按名称排序
+func (q *Queues) Queues() []Queue {
+	q.mutex.RLock()
+	queues := make([]Queue, 0, len(q.queues))
+	for _, queue := range q.queues {
+		queues = append(queues, queue.snapshot())
+	}
+	q.mutex.RUnlock()
+
+	sort.Slice(queues, func(i, j int) bool {
+		return queues[i].Name < queues[j].Name
+	})
+	return queues
+}
+
+// Watch 注册队列变更通知, 每次通知带有整个队列的快照, 执行方式见 ChannelTracker.Watch
+func (q *Queues) Watch(fn func(QueueChange)) Subscription {
+	return q.watchers.add(func(v interface{}) {
+		fn(v.(QueueChange))
+	})
+}
+
+func (q *Queues) handle(event *parse.Event) {
+	typed, err := DecodeEvent(event)
+	if err != nil {
+		utils.Log.Errorf("queues decode %s", err)
+		return
+	}
+
+	if !q.lock() {
+		return
+	}
+	queue := q.apply(typed)
+	if queue == nil {
+		q.mutex.Unlock()
+		return
+	}
+	change := QueueChange{Queue: queue.snapshot(), Event: typed}
+	q.mutex.Unlock()
+
+	q.watchers.notify(change)
+}
+
+func (q *Queues) queue(name string) *trackedQueue {
+	queue, ok := q.queues[name]
+	if !ok {
+		queue = newTrackedQueue(name)
+		q.queues[name] = queue
+	}
+	return queue
+}
+
+// apply 以事件更新状态, 返回改变的队列
+func (q *Queues) apply(typed Event) *trackedQueue {
+	switch e := typed.(type) {
+	case *QueueMemberAddedEvent:
+		queue := q.queue(e.Queue)
+		q.touch(queueMemberKey(e.Queue, e.Interface))
+		applyMemberHeader(queue.member(e.Interface), &e.QueueMemberHeader)
+		return queue
+	case *QueueMemberPauseEvent:
+		queue := q.queue(e.Queue)
+		q.touch(queueMemberKey(e.Queue, e.Interface))
+		applyMemberHeader(queue.member(e.Interface), &e.QueueMemberHeader)
+		return queue
+	case *QueueMemberStatusEvent:
+		queue := q.queue(e.Queue)
+		q.touch(queueMemberKey(e.Queue, e.Interface))
+		applyMemberHeader(queue.member(e.Interface), &e.QueueMemberHeader)
+		return queue
+	case *QueueMemberPenaltyEvent:
+		queue := q.queue(e.Queue)
+		q.touch(queueMemberKey(e.Queue, e.Interface))
+		applyMemberHeader(queue.member(e.Interface), &e.QueueMemberHeader)
+		return queue
+	case *QueueMemberRinginuseEvent:
+		queue := q.queue(e.Queue)
+		q.touch(queueMemberKey(e.Queue, e.Interface))
+		applyMemberHeader(queue.member(e.Interface), &e.QueueMemberHeader)
+		return queue
+	case *QueueMemberRemovedEvent:
+		queue := q.queue(e.Queue)
+		q.touch(queueMemberKey(e.Queue, e.Interface))
+		delete(queue.members, e.Interface)
+		return queue
+	case *QueueCallerJoinEvent:
+		queue := q.queue(e.Queue)
+		q.touch(queueCallerKey(e.Queue, e.Uniqueid))
+		joined := e.Timestamp
+		if joined.IsZero() {
+			joined = time.Now()
+		}
+		queue.callers[e.Uniqueid] = &QueueCaller{
+			Uniqueid:     e.Uniqueid,
+			Channel:      e.Channel,
+			CallerIDNum:  e.CallerIDNum,
+			CallerIDName: e.CallerIDName,
+			Position:     e.Position,
+			Joined:       joined,
+		}
+		return queue
+	case *QueueCallerLeaveEvent:
+		queue := q.queue(e.Queue)
+		q.touch(queueCallerKey(e.Queue, e.Uniqueid))
+		delete(queue.callers, e.Uniqueid)
+		queue.renumber()
+		return queue
+	case *QueueCallerAbandonEvent:
+		// 之后还会收到 QueueCallerLeave
+		queue := q.queue(e.Queue)
+		q.touch(queueStatsKey(e.Queue))
+		queue.Abandoned++
+		return queue
+	case *AgentCalledEvent:
+		queue := q.queue(e.Queue)
+		q.touch(queueMemberKey(e.Queue, e.Interface))
+		queue.member(e.Interface).Ringing = e.Uniqueid
+		return queue
+	case *AgentRingNoAnswerEvent:
+		queue := q.queue(e.Queue)
+		q.touch(queueMemberKey(e.Queue, e.Interface))
+		queue.member(e.Interface).Ringing = ""
+		return queue
+	case *AgentConnectEvent:
+		queue := q.queue(e.Queue)
+		q.touch(queueMemberKey(e.Queue, e.Interface))
+		member := queue.member(e.Interface)
+		member.Ringing = ""
+		member.Connected = e.Uniqueid
+		return queue
+	case *AgentCompleteEvent:
+		queue := q.queue(e.Queue)
+		q.touch(queueMemberKey(e.Queue, e.Interface), queueStatsKey(e.Queue))
+		queue.member(e.Interface).Connected = ""
+		queue.Completed++
+		return queue
+	}
+	return nil
+}
+
+// seed 通过 QueueStatus 重新同步全部队列, 合并方式见 merge
+// 不使用 QueueSummary: 它的 Available 和 LongestHoldTime 只是请求时刻的汇总, 由 QueueStatus 返回的
+// 成员和来电即可算出, 且之后随事件更新, 见 Queue.Available 和 Queue.LongestWait
+func (q *Queues) seed() {
+	var events []parse.Event
+	var changes []QueueChange
+	q.resync(func(ctx context.Context) (err error) {
+		_, events, err = q.amigo.request(ctx, NewAction("QueueStatus"))
+		return err
+	}, func(touched map[string]bool) {
+		q.merge(queueStatus(events), touched)
+		changes = make([]QueueChange, 0, len(q.queues))
+		for _, queue := range q.queues {
+			changes = append(changes, QueueChange{Queue: queue.snapshot()})
+		}
+	})
+
+	for _, change := range changes {
+		q.watchers.notify(change)
+	}
+}
+
+// merge 合并 QueueStatus 的结果, 同步期间有事件的成员和来电以事件为准,
+// 有 AgentComplete/QueueCallerAbandon 的队列计数取较大值
+func (q *Queues) merge(queues map[string]*trackedQueue, touched map[string]bool) {
+	for name, listed := range queues {
+		queue := q.queue(name)
+		completed, abandoned := queue.Completed, queue.Abandoned
+		queue.Queue = listed.Queue
+		if touched[queueStatsKey(name)] {
+			if completed > queue.Completed {
+				queue.Completed = completed
+			}
+			if abandoned > queue.Abandoned {
+				queue.Abandoned = abandoned
+			}
+		}
+		for iface, member := range listed.members {
+			if !touched[queueMemberKey(name, iface)] {
+				queue.members[iface] = member
+			}
+		}
+		for uniqueid, caller := range listed.callers {
+			if !touched[queueCallerKey(name, uniqueid)] {
+				queue.callers[uniqueid] = caller
+			}
+		}
+	}
+
+	for name, queue := range q.queues {
+		listed, ok := queues[name]
+		if !ok {
+			listed = newTrackedQueue(name)
+		}
+		for iface := range queue.members {
+			if _, in := listed.members[iface]; !in && !touched[queueMemberKey(name, iface)] {
+				delete(queue.members, iface)
+			}
+		}
+		for uniqueid := range queue.callers {
+			if _, in := listed.callers[uniqueid]; !in && !touched[queueCallerKey(name, uniqueid)] {
+				delete(queue.callers, uniqueid)
+			}
+		}
+		queue.renumber()
+		if !ok && !touched[queueStatsKey(name)] && len(queue.members) == 0 && len(queue.callers) == 0 {
+			delete(q.queues, name)
+		}
+	}
+}
+
+// 重新同步时登记事件改变的对象的 key
+func queueMemberKey(queue, iface string) string {
+	return "member:" + queue + "/" + iface
+}
+
+func queueCallerKey(queue, uniqueid string) string {
+	return "caller:" + queue + "/" + uniqueid
+}
+
+func queueStatsKey(queue string) string {
+	return "stats:" + queue
+}
+
+// queueStatus 由 QueueStatus 返回的事件构建队列表
+func queueStatus(events []parse.Event) map[string]*trackedQueue {
+	now := time.Now()
+	queues := make(map[string]*trackedQueue)
+	get := func(name string) *trackedQueue {
+		queue, ok := queues[name]
+		if !ok {
+			queue = newTrackedQueue(name)
+			queues[name] = queue
+		}
+		return queue
+	}
+	for i := range events {
+		typed, err := DecodeEvent(&events[i])
+		if err != nil {
+			continue
+		}
+		switch e := typed.(type) {
+		case *QueueParamsEvent:
+			queue := get(e.Queue)
+			queue.Strategy = e.Strategy
+			queue.Max = e.Max
+			queue.Holdtime = e.Holdtime
+			queue.TalkTime = e.TalkTime
+			queue.Completed = e.Completed
+			queue.Abandoned = e.Abandoned
+			queue.ServiceLevel = e.ServiceLevel
+			queue.ServicelevelPerf = e.ServicelevelPerf
+			queue.ServicelevelPerf2 = e.ServicelevelPerf2
+			queue.Weight = e.Weight
+		case *QueueMemberEvent:
+			member := get(e.Queue).member(e.Location)
+			applyMemberHeader(member, &QueueMemberHeader{
+				MemberName:     e.Name,
+				StateInterface: e.StateInterface,
+				Membership:     e.Membership,
+				Penalty:        e.Penalty,
+				CallsTaken:     e.CallsTaken,
+				LastCall:       e.LastCall,
+				LastPause:      e.LastPause,
+				LoginTime:      e.LoginTime,
+				InCall:         e.InCall,
+				Status:         e.Status,
+				Paused:         e.Paused,
+				PausedReason:   e.PausedReason,
+				Wrapuptime:     e.Wrapuptime,
+			})
+		case *QueueEntryEvent:
+			get(e.Queue).callers[e.Uniqueid] = &QueueCaller{
+				Uniqueid:     e.Uniqueid,
+				Channel:      e.Channel,
+				CallerIDNum:  e.CallerIDNum,
+				CallerIDName: e.CallerIDName,
+				Position:     e.Position,
+				Joined:       now.Add(-e.Wait),
+			}
+		}
+	}
+
+	return queues
+}
+
+// QueueAddRequest QueueAdd 的参数
+type QueueAddRequest struct {
+	Queue          string
+	Interface      string
+	MemberName     string
+	StateInterface string
+	Penalty        int
+	Paused         bool
+	Reason         string
+}
+
+// QueueAdd 向队列添加动态成员
+func (a *Amigo) QueueAdd(ctx context.Context, req QueueAddRequest) error {
+	action := NewAction("QueueAdd").
+		Add("Queue", req.Queue).
+		Add("Interface", req.Interface).
+		Add("Penalty", fmt.Sprint(req.Penalty)).
+		Add("Paused", fmt.Sprint(req.Paused))
+	if req.MemberName != "" {
+		action.Add("MemberName", req.MemberName)
+	}
+	if req.StateInterface != "" {
+		action.Add("StateInterface", req.StateInterface)
+	}
+	if req.Reason != "" {
+		action.Add("Reason", req.Reason)
+	}
+	_, _, err := a.request(ctx, action)
+	return err
+}
+
+// QueueRemove 从队列移除动态成员
+func (a *Amigo) QueueRemove(ctx context.Context, queue, iface string) error {
+	_, _, err := a.request(ctx, NewAction("QueueRemove").Add("Queue", queue).Add("Interface", iface))
+	return err
+}
+
+// QueuePause 暂停或恢复成员, queue 为空时作用于成员所在的全部队列
+func (a *Amigo) QueuePause(ctx context.Context, queue, iface string, paused bool, reason string) error {
+	action := NewAction("QueuePause").Add("Interface", iface).Add("Paused", fmt.Sprint(paused))
+	if queue != "" {
+		action.Add("Queue", queue)
+	}
+	if reason != "" {
+		action.Add("Reason", reason)
+	}
+	_, _, err := a.request(ctx, action)
+	return err
+}
+
+// QueuePenalty 设置成员的 penalty, queue 为空时作用于成员所在的全部队列
+func (a *Amigo) QueuePenalty(ctx context.Context, queue, iface string, penalty int) error {
+	action := NewAction("QueuePenalty").Add("Interface", iface).Add("Penalty", fmt.Sprint(penalty))
+	if queue != "" {
+		action.Add("Queue", queue)
+	}
+	_, _, err := a.request(ctx, action)
+	return err
+}
+
+// QueueLogRequest QueueLog 的参数, 写入 queue_log 的一行
+type QueueLogRequest struct {
+	Queue     string
+	Event     string
+	Uniqueid  string
+	Interface string
+	Message   string
+}
+
+// QueueLog 向 queue_log 写入自定义事件
+func (a *Amigo) QueueLog(ctx context.Context, req QueueLogRequest) error {
+	action := NewAction("QueueLog").Add("Queue", req.Queue).Add("Event", req.Event)
+	for _, header := range []parse.Header{
+		{Key: "Uniqueid", Value: req.Uniqueid},
+		{Key: "Interface", Value: req.Interface},
+		{Key: "Message", Value: req.Message},
+	} {
+		if header.Value != "" {
+			action.Add(header.Key, header.Value)
+		}
+	}
+	_, _, err := a.request(ctx, action)
+	return err
+}
+
+// QueueSummary 返回队列摘要, queue 为空时返回全部队列
+func (a *Amigo) QueueSummary(ctx context.Context, queue string) ([]QueueSummaryEvent, error) {
+	action := NewAction("QueueSummary")
+	if queue != "" {
+		action.Add("Queue", queue)
+	}
+	_, events, err := a.request(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]QueueSummaryEvent, 0, len(events))
+	for i := range events {
+		if events[i].Get("Event") != "QueueSummary" {
+			continue
+		}
+		var summary QueueSummaryEvent
+		if err := parse.DecodeMessage(events[i].Message, &summary); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
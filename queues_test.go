@@ -0,0 +1,110 @@
+package amigo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+)
+
+// queueMember 构建 QueueMember 事件的成员字段
+func queueMember(name, iface string, kv ...string) amitest.Message {
+	return amitest.Event(name, append([]string{
+		"Queue", "sales",
+		"MemberName", iface,
+		"Interface", iface,
+		"Status", "1",
+	}, kv...)...)
+}
+
+func TestQueues(t *testing.T) {
+	srv := amitest.NewServer()
+	srv.RespondList("QueueStatus", []amitest.Message{
+		amitest.Event("QueueParams", "Queue", "sales", "Strategy", "ringall", "Completed", "3"),
+		amitest.Event("QueueMember", "Queue", "sales", "Name", "Alice", "Location", "PJSIP/1001", "Status", "1", "Paused", "0"),
+		amitest.Event("QueueMember", "Queue", "sales", "Name", "Bob", "Location", "PJSIP/1002", "Status", "1", "Paused", "1", "PausedReason", "lunch"),
+		amitest.Event("QueueEntry", "Queue", "sales", "Position", "1", "Uniqueid", "9.1", "CallerIDNum", "5551", "Wait", "30"),
+	}, amitest.Event("QueueStatusComplete"))
+	a, _ := newTestAmigo(t, srv)
+	queues := NewQueues(a)
+	defer queues.Close()
+
+	if !waitUntil(func() bool {
+		queue, ok := queues.Get("sales")
+		return ok && len(queue.Members) == 2
+	}) {
+		t.Fatalf("Queues() = %+v", queues.Queues())
+	}
+	queue, _ := queues.Get("sales")
+	if queue.Available() != 1 || queue.Paused() != 1 || queue.Members[1].PausedReason != "lunch" || queue.Completed != 3 {
+		t.Errorf("seeded queue = %+v", queue)
+	}
+	if len(queue.Callers) != 1 || queue.LongestWait() < 30*time.Second {
+		t.Errorf("seeded callers = %+v, LongestWait() = %v", queue.Callers, queue.LongestWait())
+	}
+
+	changes := make(chan QueueChange, 16)
+	queues.Watch(func(change QueueChange) { changes <- change })
+
+	steps := []struct {
+		name  string
+		event amitest.Message
+		check func(q Queue) bool
+	}{
+		{
+			name:  "pause",
+			event: queueMember("QueueMemberPause", "PJSIP/1001", "Paused", "1", "PausedReason", "break"),
+			check: func(q Queue) bool {
+				return q.Paused() == 2 && q.Available() == 0 && q.Members[0].PausedReason == "break"
+			},
+		},
+		{
+			name:  "unpause",
+			event: queueMember("QueueMemberPause", "PJSIP/1001", "Paused", "0"),
+			check: func(q Queue) bool { return q.Paused() == 1 && q.Available() == 1 },
+		},
+		{
+			name:  "caller join",
+			event: amitest.Event("QueueCallerJoin", "Queue", "sales", "Uniqueid", "9.2", "Channel", "PJSIP/trunk-2", "Position", "2", "Count", "2"),
+			check: func(q Queue) bool { return len(q.Callers) == 2 && q.Callers[1].Uniqueid == "9.2" },
+		},
+		{
+			name:  "ring",
+			event: amitest.Event("AgentCalled", "Queue", "sales", "Interface", "PJSIP/1001", "Uniqueid", "9.1"),
+			check: func(q Queue) bool { return q.Members[0].Ringing == "9.1" && q.Available() == 0 },
+		},
+		{
+			name:  "connect",
+			event: amitest.Event("AgentConnect", "Queue", "sales", "Interface", "PJSIP/1001", "Uniqueid", "9.1", "HoldTime", "31"),
+			check: func(q Queue) bool { return q.Members[0].Ringing == "" && q.Members[0].Connected == "9.1" },
+		},
+		{
+			name:  "caller leave",
+			event: amitest.Event("QueueCallerLeave", "Queue", "sales", "Uniqueid", "9.1", "Position", "1", "Count", "1"),
+			check: func(q Queue) bool {
+				return len(q.Callers) == 1 && q.Callers[0].Uniqueid == "9.2" && q.Callers[0].Position == 1
+			},
+		},
+		{
+			name:  "complete",
+			event: amitest.Event("AgentComplete", "Queue", "sales", "Interface", "PJSIP/1001", "Uniqueid", "9.1", "Reason", "agent"),
+			check: func(q Queue) bool { return q.Members[0].Connected == "" && q.Completed == 4 && q.Available() == 1 },
+		},
+		{
+			name:  "member removed",
+			event: queueMember("QueueMemberRemoved", "PJSIP/1002"),
+			check: func(q Queue) bool { return len(q.Members) == 1 && q.Paused() == 0 },
+		},
+	}
+	for _, step := range steps {
+		srv.Inject(step.event)
+		select {
+		case change := <-changes:
+			if !step.check(change.Queue) {
+				t.Errorf("%s: queue = %+v", step.name, change.Queue)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: no change", step.name)
+		}
+	}
+}
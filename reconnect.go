@@ -0,0 +1,109 @@
+package amigo
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// ReconnectPolicy 决定断线后的重连等待时间
+type ReconnectPolicy interface {
+	// Next 返回第 attempt 次(从 1 开始)重连前的等待时间, 返回 false 表示放弃重连
+	Next(attempt int) (time.Duration, bool)
+}
+
+// ConstantBackoff 固定间隔重连
+type ConstantBackoff struct {
+	Interval time.Duration
+	// MaxAttempts 最大重连次数, 0 不限制
+	MaxAttempts int
+}
+
+// Next implements ReconnectPolicy
+func (b ConstantBackoff) Next(attempt int) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && attempt > b.MaxAttempts {
+		return 0, false
+	}
+	return b.Interval, true
+}
+
+// ExponentialBackoff 指数退避重连, 避免大量客户端同时重连同一台 Asterisk
+type ExponentialBackoff struct {
+	// Initial 首次等待时间, 默认 1s
+	Initial time.Duration
+	// Max 等待时间上限, 0 不限制
+	Max time.Duration
+	// Multiplier 每次增长倍数, 默认 2
+	Multiplier float64
+	// Jitter 随机缩短等待时间的比例(0~1), 0 不随机
+	Jitter float64
+	// MaxAttempts 最大重连次数, 0 不限制
+	MaxAttempts int
+}
+
+// Next implements ReconnectPolicy
+func (b ExponentialBackoff) Next(attempt int) (time.Duration, bool) {
+	if b.MaxAttempts > 0 && attempt > b.MaxAttempts {
+		return 0, false
+	}
+
+	initial := b.Initial
+	if initial <= 0 {
+		initial = time.Second
+	}
+	multiplier := b.Multiplier
+	if multiplier <= 1 {
+		multiplier = 2
+	}
+
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt-1))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+	if b.Jitter > 0 {
+		jitter := math.Min(b.Jitter, 1)
+		delay -= delay * jitter * rand.Float64()
+	}
+	return time.Duration(delay), true
+}
+
+func (s *Settings) reconnectPolicy() ReconnectPolicy {
+	if s.ReconnectPolicy != nil {
+		return s.ReconnectPolicy
+	}
+	interval := s.ReconnectInterval
+	if interval == 0 {
+		interval = utils.ReconnectInterval
+	}
+	return ConstantBackoff{Interval: interval}
+}
+
+// reconnect 按 ReconnectPolicy 等待后重建连接, 放弃时通知 Reconnect_Give_Up
+func (a *Amigo) reconnect() {
+	a.mutex.Lock()
+	a.attempts++
+	attempt := a.attempts
+	a.mutex.Unlock()
+
+	delay, ok := a.settings.reconnectPolicy().Next(attempt)
+	if !ok {
+		utils.Log.Errorf("ami reconnect give up after %d attempts", attempt-1)
+		a.eventEmitter.Emit("AMI_Connect", pkg.Reconnect_Give_Up, attempt-1)
+		if a.settings.OnReconnectGiveUp != nil {
+			a.settings.OnReconnectGiveUp(attempt - 1)
+		}
+		return
+	}
+
+	a.eventEmitter.Emit("AMI_Connect", pkg.Reconnecting, attempt, delay)
+	select {
+	case <-time.After(delay):
+	case <-a.closed:
+		return
+	}
+	utils.Log.Errorf("reconnect and reinit ami, attempt %d", attempt)
+	a.initAMI()
+}
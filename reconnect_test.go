@@ -0,0 +1,54 @@
+package amigo
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+func TestReconnect(t *testing.T) {
+	srv := amitest.NewServer()
+	defer srv.Close()
+	srv.Drop("CoreStatus")
+	a := New(&Settings{Host: srv.Host, Port: srv.Port, Username: srv.Username, Password: srv.Secret, ReconnectInterval: 10 * time.Millisecond}, nil)
+	defer a.Close(context.Background())
+
+	var logins int32
+	sub := a.onLogin(func() { atomic.AddInt32(&logins, 1) })
+	a.Connect()
+	if !waitUntil(func() bool { return atomic.LoadInt32(&logins) == 1 }) {
+		t.Fatal("not logged in")
+	}
+
+	// 断线时等待中的 action 以 ErrNotConnected 结束
+	future := a.SendAsync(map[string]string{"Action": "CoreStatus"})
+	if _, ok := srv.WaitAction("CoreStatus", 2*time.Second); !ok {
+		t.Fatal("no CoreStatus received")
+	}
+	srv.CloseConnections()
+	if _, _, err := future.Wait(); err != utils.ErrNotConnected {
+		t.Errorf("pending action err = %v, want %v", err, utils.ErrNotConnected)
+	}
+
+	if !waitUntil(func() bool { return atomic.LoadInt32(&logins) == 2 && a.loggedInNow() }) {
+		t.Fatalf("not logged in again, logins %d", atomic.LoadInt32(&logins))
+	}
+	if n := srv.Connections(); n != 1 {
+		t.Errorf("Connections() = %d after reconnect", n)
+	}
+
+	// 取消后不再执行
+	sub.Unsubscribe()
+	srv.CloseConnections()
+	if !waitUntil(func() bool { return srv.Connections() == 1 && a.loggedInNow() }) {
+		t.Fatal("not logged in after second reconnect")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := atomic.LoadInt32(&logins); n != 2 {
+		t.Errorf("onLogin ran %d times after Unsubscribe, want 2", n)
+	}
+}
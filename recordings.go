@@ -0,0 +1,402 @@
+package amigo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// RecordDirection MixMonitor 的录音方向
+type RecordDirection string
+
+const (
+	// RecordRead 通道收到的声音, 即对端说话
+	RecordRead RecordDirection = "read"
+	// RecordWrite 发往通道的声音
+	RecordWrite RecordDirection = "write"
+	// RecordBoth 双向
+	RecordBoth RecordDirection = "both"
+)
+
+// MixMonitorRequest MixMonitor 的参数
+type MixMonitorRequest struct {
+	Channel string
+	// File 录音文件, 相对路径保存在 Asterisk 的 monitor 目录, 扩展名决定格式
+	File string
+	// Options MixMonitor 应用的选项, 如 "b" 仅在桥接后录音
+	Options string
+	// Command 录音结束后执行的命令
+	Command string
+}
+
+// MixMonitor 开始录音, 返回 Asterisk 13+ 分配的 MixMonitorID
+func (a *Amigo) MixMonitor(ctx context.Context, req MixMonitorRequest) (string, error) {
+	action := NewAction("MixMonitor").Add("Channel", req.Channel).Add("File", req.File)
+	if req.Options != "" {
+		action.Add("Options", req.Options)
+	}
+	if req.Command != "" {
+		action.Add("Command", req.Command)
+	}
+	data, _, err := a.request(ctx, action)
+	if err != nil {
+		return "", err
+	}
+	return data["MixMonitorID"], nil
+}
+
+// StopMixMonitor 停止录音, mixMonitorID 为空时停止该通道上的 MixMonitor
+func (a *Amigo) StopMixMonitor(ctx context.Context, channel, mixMonitorID string) error {
+	action := NewAction("StopMixMonitor").Add("Channel", channel)
+	if mixMonitorID != "" {
+		action.Add("MixMonitorID", mixMonitorID)
+	}
+	_, _, err := a.request(ctx, action)
+	return err
+}
+
+// MixMonitorMute 静音或恢复录音的一个方向, 录音文件在静音期间写入静音
+// 如刷卡支付时以 RecordBoth 暂停录音
+func (a *Amigo) MixMonitorMute(ctx context.Context, channel string, direction RecordDirection, mute bool) error {
+	state := "0"
+	if mute {
+		state = "1"
+	}
+	_, _, err := a.request(ctx, NewAction("MixMonitorMute").
+		Add("Channel", channel).Add("Direction", string(direction)).Add("State", state))
+	return err
+}
+
+// PauseMonitor 暂停 Monitor 录音, MixMonitor 使用 MixMonitorMute
+func (a *Amigo) PauseMonitor(ctx context.Context, channel string) error {
+	_, _, err := a.request(ctx, NewAction("PauseMonitor").Add("Channel", channel))
+	return err
+}
+
+// UnpauseMonitor 恢复 Monitor 录音
+func (a *Amigo) UnpauseMonitor(ctx context.Context, channel string) error {
+	_, _, err := a.request(ctx, NewAction("UnpauseMonitor").Add("Channel", channel))
+	return err
+}
+
+// RecordingPause 录音的一段静音区间, End 为零值表示仍在静音
+type RecordingPause struct {
+	Direction RecordDirection
+	Start     time.Time
+	End       time.Time
+}
+
+// Recording 一个通道的 MixMonitor 录音
+type Recording struct {
+	Channel  string
+	Uniqueid string
+	Linkedid string
+	// File 录音文件, 来自 MixMonitor 设置的 MIXMONITOR_FILENAME 通道变量
+	File    string
+	Started time.Time
+	// Stopped 录音结束的时间, 此时文件已关闭
+	Stopped time.Time
+	// Muted 当前静音的方向, 未静音时为空
+	Muted RecordDirection
+	// Pauses 按时间排列的全部静音区间
+	Pauses []RecordingPause
+}
+
+// Active 录音尚未结束
+func (r *Recording) Active() bool {
+	return r.Stopped.IsZero()
+}
+
+// PausedDuration 静音的总时长, 未结束的区间计算到录音结束或当前时间
+func (r *Recording) PausedDuration() time.Duration {
+	end := r.Stopped
+	if end.IsZero() {
+		end = time.Now()
+	}
+	var d time.Duration
+	for _, pause := range r.Pauses {
+		if pause.End.IsZero() {
+			d += end.Sub(pause.Start)
+		} else {
+			d += pause.End.Sub(pause.Start)
+		}
+	}
+	return d
+}
+
+func (r *Recording) clone() Recording {
+	copied := *r
+	copied.Pauses = append([]RecordingPause(nil), r.Pauses...)
+	return copied
+}
+
+// RecordingChangeType 录音变更类型
+type RecordingChangeType int
+
+const (
+	// RecordingStarted 开始录音
+	RecordingStarted RecordingChangeType = iota
+	// RecordingMuted 录音静音的方向改变, 且至少一个方向静音
+	RecordingMuted
+	// RecordingUnmuted 录音恢复为双向录音
+	RecordingUnmuted
+	// RecordingFinalized 录音结束, 文件已关闭
+	RecordingFinalized
+)
+
+func (t RecordingChangeType) String() string {
+	switch t {
+	case RecordingStarted:
+		return "Started"
+	case RecordingMuted:
+		return "Muted"
+	case RecordingUnmuted:
+		return "Unmuted"
+	case RecordingFinalized:
+		return "Finalized"
+	}
+	return "Unknown"
+}
+
+// RecordingChange Recordings 的变更通知
+type RecordingChange struct {
+	Type      RecordingChangeType
+	Recording Recording
+	// Event 引起变更的事件, 断线期间结束、登录后按 CoreShowChannels 结束的录音为 nil
+	Event Event
+}
+
+var recordingEvents = []string{"MixMonitorStart", "MixMonitorStop", "MixMonitorMute", "VarSet", "Hangup"}
+
+// mixMonitorFilenameVar MixMonitor 启动时设置的通道变量
+const mixMonitorFilenameVar = "MIXMONITOR_FILENAME"
+
+type trackedRecording struct {
+	Recording
+	read, write bool
+}
+
+// Recordings 按通道 Uniqueid 跟踪进行中的 MixMonitor 录音 (Asterisk 14+ 事件)
+// 每个通道只跟踪一个录音; 需要 dialplan 读权限以收到 VarSet 获得文件名.
+// 每次登录后通过 CoreShowChannels 结束已不存在的通道上的录音
+type Recordings struct {
+	tracker
+	recordings map[string]*trackedRecording
+	// files MixMonitorStart 之前收到的 MIXMONITOR_FILENAME
+	files map[string]string
+}
+
+// NewRecordings 新建 Recordings 并开始跟踪
+func NewRecordings(a *Amigo) *Recordings {
+	r := &Recordings{
+		recordings: make(map[string]*trackedRecording),
+		files:      make(map[string]string),
+	}
+	r.start(a, "recordings", recordingEvents, r.handle, r.seed)
+	return r
+}
+
+// Close 停止跟踪, Get/Active 仍返回最后的录音表, 之后结束的录音不再通知
+func (r *Recordings) Close() {
+	r.close()
+}
+
+// Get 按通道 Uniqueid 查找进行中的录音
+func (r *Recordings) Get(uniqueid string) (Recording, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if rec, ok := r.recordings[uniqueid]; ok {
+		return rec.clone(), true
+	}
+	return Recording{}, false
+}
+
+// ByChannel 按通道名查找进行中的录音
+func (r *Recordings) ByChannel(name string) (Recording, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for _, rec := range r.recordings {
+		if rec.Channel == name {
+			return rec.clone(), true
+		}
+	}
+	return Recording{}, false
+}
+
+// Active 返回全部进行中的录音, 按开始时间排序
+func (r *Recordings) Active() []Recording {
+	r.mutex.RLock()
+	list := make([]Recording, 0, len(r.recordings))
+	for _, rec := range r.recordings {
+		list = append(list, rec.clone())
+	}
+	r.mutex.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].Started.Equal(list[j].Started) {
+			return list[i].Started.Before(list[j].Started)
+		}
+		return list[i].Uniqueid < list[j].Uniqueid
+	})
+	return list
+}
+
+// Watch 注册录音变更通知, 执行方式见 ChannelTracker.Watch
+// RecordingFinalized 中的 Recording 带有完整的静音区间, 可用于证明录音已恢复
+func (r *Recordings) Watch(fn func(RecordingChange)) Subscription {
+	return r.watchers.add(func(v interface{}) {
+		fn(v.(RecordingChange))
+	})
+}
+
+func (r *Recordings) handle(event *parse.Event) {
+	typed, err := DecodeEvent(event)
+	if err != nil {
+		utils.Log.Errorf("recordings decode %s", err)
+		return
+	}
+
+	now := time.Now()
+	if !r.lock() {
+		return
+	}
+	var change *RecordingChange
+	switch e := typed.(type) {
+	case *VarSetEvent:
+		if e.Variable != mixMonitorFilenameVar || e.Uniqueid == "" {
+			break
+		}
+		r.touch(e.Uniqueid)
+		if rec, ok := r.recordings[e.Uniqueid]; ok {
+			rec.File = e.Value
+		} else {
+			r.files[e.Uniqueid] = e.Value
+		}
+	case *MixMonitorStartEvent:
+		r.touch(e.Uniqueid)
+		rec := &trackedRecording{Recording: Recording{
+			Channel:  e.Channel,
+			Uniqueid: e.Uniqueid,
+			Linkedid: e.Linkedid,
+			File:     r.files[e.Uniqueid],
+			Started:  now,
+		}}
+		delete(r.files, e.Uniqueid)
+		r.recordings[e.Uniqueid] = rec
+		change = &RecordingChange{Type: RecordingStarted, Recording: rec.clone(), Event: typed}
+	case *MixMonitorMuteEvent:
+		r.touch(e.Uniqueid)
+		rec, ok := r.recordings[e.Uniqueid]
+		if !ok {
+			break
+		}
+		switch RecordDirection(e.Direction) {
+		case RecordRead:
+			rec.read = e.State
+		case RecordWrite:
+			rec.write = e.State
+		default:
+			rec.read, rec.write = e.State, e.State
+		}
+		if rec.mute(now) {
+			change = &RecordingChange{Type: RecordingMuted, Recording: rec.clone(), Event: typed}
+			if rec.Muted == "" {
+				change.Type = RecordingUnmuted
+			}
+		}
+	case *MixMonitorStopEvent:
+		change = r.finalize(e.Uniqueid, now, typed)
+	case *HangupEvent:
+		delete(r.files, e.Uniqueid)
+		change = r.finalize(e.Uniqueid, now, typed)
+	}
+	r.mutex.Unlock()
+
+	if change != nil {
+		r.watchers.notify(*change)
+	}
+}
+
+// mute 按 read/write 更新 Muted 和静音区间, 返回静音方向是否改变
+func (rec *trackedRecording) mute(now time.Time) bool {
+	var muted RecordDirection
+	switch {
+	case rec.read && rec.write:
+		muted = RecordBoth
+	case rec.read:
+		muted = RecordRead
+	case rec.write:
+		muted = RecordWrite
+	}
+	if muted == rec.Muted {
+		return false
+	}
+	if n := len(rec.Pauses); n > 0 && rec.Pauses[n-1].End.IsZero() {
+		rec.Pauses[n-1].End = now
+	}
+	if muted != "" {
+		rec.Pauses = append(rec.Pauses, RecordingPause{Direction: muted, Start: now})
+	}
+	rec.Muted = muted
+	return true
+}
+
+// finalize 结束录音并从录音表移除, 调用方持有 mutex
+func (r *Recordings) finalize(uniqueid string, now time.Time, typed Event) *RecordingChange {
+	rec, ok := r.recordings[uniqueid]
+	if !ok {
+		return nil
+	}
+	delete(r.recordings, uniqueid)
+	if n := len(rec.Pauses); n > 0 && rec.Pauses[n-1].End.IsZero() {
+		rec.Pauses[n-1].End = now
+	}
+	rec.Stopped = now
+	return &RecordingChange{Type: RecordingFinalized, Recording: rec.clone(), Event: typed}
+}
+
+// seed 断线期间挂断的通道收不到 MixMonitorStop/Hangup, 登录后按 CoreShowChannels 结束其录音
+func (r *Recordings) seed() {
+	var events []parse.Event
+	skip := false
+	var changes []*RecordingChange
+	r.resync(func(ctx context.Context) (err error) {
+		r.mutex.RLock()
+		skip = len(r.recordings) == 0 && len(r.files) == 0
+		r.mutex.RUnlock()
+		if skip {
+			return nil
+		}
+		_, events, err = r.amigo.request(ctx, NewAction("CoreShowChannels"))
+		return err
+	}, func(touched map[string]bool) {
+		if skip {
+			return
+		}
+		listed := make(map[string]bool, len(events))
+		for i := range events {
+			if events[i].Get("Event") == "CoreShowChannel" {
+				listed[events[i].Get("Uniqueid")] = true
+			}
+		}
+		// 同步期间开始的录音可能不在列表中
+		now := time.Now()
+		for uniqueid := range r.files {
+			if !listed[uniqueid] && !touched[uniqueid] {
+				delete(r.files, uniqueid)
+			}
+		}
+		for uniqueid := range r.recordings {
+			if !listed[uniqueid] && !touched[uniqueid] {
+				changes = append(changes, r.finalize(uniqueid, now, nil))
+			}
+		}
+	})
+
+	for _, change := range changes {
+		r.watchers.notify(*change)
+	}
+}
@@ -0,0 +1,114 @@
+package amigo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/pkg/amitest"
+)
+
+// waitRecordingChange 读取下一个录音变更
+func waitRecordingChange(t *testing.T, changes chan RecordingChange) RecordingChange {
+	t.Helper()
+	select {
+	case change := <-changes:
+		return change
+	case <-time.After(2 * time.Second):
+		t.Fatal("no recording change")
+	}
+	return RecordingChange{}
+}
+
+func TestRecordingsMute(t *testing.T) {
+	srv := amitest.NewServer()
+	a, _ := newTestAmigo(t, srv)
+	recordings := NewRecordings(a)
+	defer recordings.Close()
+	changes := make(chan RecordingChange, 16)
+	recordings.Watch(func(change RecordingChange) { changes <- change })
+
+	srv.Inject(channelEvent("VarSet", "1.1", "1.1", "Variable", "MIXMONITOR_FILENAME", "Value", "/var/spool/asterisk/monitor/1.1.wav"))
+	srv.Inject(channelEvent("MixMonitorStart", "1.1", "1.1"))
+	if change := waitRecordingChange(t, changes); change.Type != RecordingStarted || change.Recording.File != "/var/spool/asterisk/monitor/1.1.wav" {
+		t.Fatalf("change = %v %+v", change.Type, change.Recording)
+	}
+
+	steps := []struct {
+		name      string
+		direction string
+		state     string
+		want      RecordingChangeType
+		muted     RecordDirection
+		pauses    int
+	}{
+		{name: "mute both", direction: "both", state: "1", want: RecordingMuted, muted: RecordBoth, pauses: 1},
+		{name: "unmute read", direction: "read", state: "0", want: RecordingMuted, muted: RecordWrite, pauses: 2},
+		{name: "unmute write", direction: "write", state: "0", want: RecordingUnmuted, pauses: 2},
+		{name: "mute read", direction: "read", state: "1", want: RecordingMuted, muted: RecordRead, pauses: 3},
+	}
+	for _, step := range steps {
+		srv.Inject(channelEvent("MixMonitorMute", "1.1", "1.1", "Direction", step.direction, "State", step.state))
+		change := waitRecordingChange(t, changes)
+		rec := change.Recording
+		if change.Type != step.want || rec.Muted != step.muted || len(rec.Pauses) != step.pauses {
+			t.Fatalf("%s: change = %v %+v", step.name, change.Type, rec)
+		}
+		last := rec.Pauses[len(rec.Pauses)-1]
+		if (step.muted == "") != !last.End.IsZero() {
+			t.Errorf("%s: last pause = %+v", step.name, last)
+		}
+	}
+
+	srv.Inject(channelEvent("MixMonitorStop", "1.1", "1.1"))
+	change := waitRecordingChange(t, changes)
+	rec := change.Recording
+	if change.Type != RecordingFinalized || change.Event == nil || rec.Active() {
+		t.Fatalf("change = %v %+v", change.Type, rec)
+	}
+	wantDirections := []RecordDirection{RecordBoth, RecordWrite, RecordRead}
+	for i, pause := range rec.Pauses {
+		if pause.Direction != wantDirections[i] || pause.End.IsZero() || pause.End.After(rec.Stopped) {
+			t.Errorf("Pauses[%d] = %+v", i, pause)
+		}
+	}
+	if d := rec.PausedDuration(); d > rec.Stopped.Sub(rec.Started) {
+		t.Errorf("PausedDuration() = %v longer than recording", d)
+	}
+	if _, ok := recordings.Get("1.1"); ok {
+		t.Error("finalized recording still tracked")
+	}
+}
+
+func TestRecordingsResync(t *testing.T) {
+	srv := amitest.NewServer()
+	a, _ := newTestAmigo(t, srv)
+	recordings := NewRecordings(a)
+	defer recordings.Close()
+	changes := make(chan RecordingChange, 16)
+	recordings.Watch(func(change RecordingChange) { changes <- change })
+
+	srv.Inject(channelEvent("MixMonitorStart", "1.1", "1.1"))
+	srv.Inject(channelEvent("MixMonitorStart", "2.1", "2.1"))
+	srv.Inject(channelEvent("MixMonitorMute", "1.1", "1.1", "Direction", "both", "State", "1"))
+	for i := 0; i < 3; i++ {
+		waitRecordingChange(t, changes)
+	}
+
+	// 断线期间 1.1 挂断, 2.1 仍在录音
+	srv.RespondList("CoreShowChannels", []amitest.Message{
+		coreShowChannel("2.1", 6, "Up"),
+	}, amitest.Event("CoreShowChannelsComplete"))
+	srv.CloseConnections()
+
+	change := waitRecordingChange(t, changes)
+	rec := change.Recording
+	if change.Type != RecordingFinalized || change.Event != nil || rec.Uniqueid != "1.1" || rec.Active() {
+		t.Fatalf("change = %v %v %+v", change.Type, change.Event, rec)
+	}
+	if len(rec.Pauses) != 1 || !rec.Pauses[0].End.Equal(rec.Stopped) {
+		t.Errorf("Pauses = %+v, Stopped %v", rec.Pauses, rec.Stopped)
+	}
+	if active := recordings.Active(); len(active) != 1 || active[0].Uniqueid != "2.1" {
+		t.Errorf("Active() = %+v", active)
+	}
+}
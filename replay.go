@@ -0,0 +1,30 @@
+package amigo
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/tqcenglish/amigo-go/pkg/record"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// Replay 将 record.Recorder 记录的 Asterisk 事件帧交给消息处理 goroutine, 用于复现线上事件序列
+// 订阅者和 EventOn 监听者会收到与记录时相同的事件, 不需要建立连接; 已连接时与实时事件按顺序交错.
+// 记录中的响应帧对应原会话的 ActionID, 不会重放. speed 为 1 按原始速度, 2 为两倍速, <= 0 不等待.
+// 返回时最后几帧可能仍在处理中
+func (a *Amigo) Replay(ctx context.Context, r io.Reader, speed float64) error {
+	return record.Replay(ctx, r, record.In, speed, func(frame record.Frame) error {
+		if !strings.HasPrefix(frame.Data, "Event:") {
+			return nil
+		}
+		select {
+		case a.msg <- frame.Data:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-a.closed:
+			return utils.ErrClosed
+		}
+	})
+}
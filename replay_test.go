@@ -0,0 +1,56 @@
+package amigo
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/pkg/record"
+)
+
+func TestReplay(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := record.NewRecorder(&buf)
+	frames := []struct {
+		dir  record.Direction
+		data string
+	}{
+		{record.Out, "Action: Ping\r\nActionID: 1"},
+		{record.In, "Response: Success\r\nActionID: 1\r\nPing: Pong"},
+		{record.In, "Event: Newchannel\r\nUniqueid: 1.1"},
+		{record.In, "Event: Hangup\r\nUniqueid: 1.1"},
+	}
+	for _, frame := range frames {
+		recorder.Record(frame.dir, frame.data)
+	}
+
+	a := New(&Settings{}, nil)
+	defer a.Close(context.Background())
+	// 重放的响应不能结束当前会话中 ActionID 相同的 action
+	future := newFuture(a, "1", "Ping")
+	a.responses.Store("1", future)
+
+	got := make(chan string, len(frames))
+	a.Subscribe(EventFilter{}, func(event *parse.Event) {
+		got <- event.Get("Event")
+	})
+	if err := a.Replay(context.Background(), &buf, 0); err != nil {
+		t.Fatalf("Replay() err = %v", err)
+	}
+
+	want := []string{"Newchannel", "Hangup"}
+	var names []string
+	for range want {
+		names = append(names, <-got)
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("replayed events = %v, want %v", names, want)
+	}
+	select {
+	case <-future.Done():
+		t.Error("replayed response completed a pending action")
+	default:
+	}
+}
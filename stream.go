@@ -0,0 +1,157 @@
+package amigo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// OverflowPolicy EventStream 队列已满时的处理方式
+type OverflowPolicy int
+
+const (
+	// OverflowBlock 阻塞消息处理直到队列有空位, 会拖慢整个连接
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest 丢弃队列中最早的事件
+	OverflowDropOldest
+	// OverflowDropNewest 丢弃新到的事件
+	OverflowDropNewest
+	// OverflowDisconnect 关闭该订阅, Err 返回 utils.ErrStreamOverflow
+	OverflowDisconnect
+)
+
+// DefaultStreamBuffer EventStreamOptions.Buffer 默认值
+const DefaultStreamBuffer = 1024
+
+// EventStreamOptions EventStream 参数
+type EventStreamOptions struct {
+	Filter EventFilter
+	// Buffer 队列长度, 默认 DefaultStreamBuffer
+	Buffer   int
+	Overflow OverflowPolicy
+}
+
+// EventStream 带缓冲的类型化事件订阅, 消费慢时按 OverflowPolicy 处理, 不阻塞其他订阅
+type EventStream struct {
+	// C 事件 channel, ctx 结束、Amigo 关闭或 OverflowDisconnect 时关闭
+	C <-chan Event
+
+	queue    chan Event
+	overflow OverflowPolicy
+	dropped  uint64
+
+	mutex    sync.Mutex
+	done     chan struct{}
+	doneOnce sync.Once
+	err      atomic.Value
+}
+
+// Dropped 返回因队列已满丢弃的事件数
+func (s *EventStream) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Err 返回订阅异常结束的原因, 正常运行或 ctx 结束时为 nil
+func (s *EventStream) Err() error {
+	if err, ok := s.err.Load().(error); ok {
+		return err
+	}
+	return nil
+}
+
+func (s *EventStream) push(event Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	select {
+	case <-s.done:
+		return
+	case s.queue <- event:
+		return
+	default:
+	}
+
+	switch s.overflow {
+	case OverflowBlock:
+		select {
+		case s.queue <- event:
+		case <-s.done:
+		}
+	case OverflowDropOldest:
+		select {
+		case <-s.queue:
+			atomic.AddUint64(&s.dropped, 1)
+		default:
+		}
+		select {
+		case s.queue <- event:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	case OverflowDropNewest:
+		atomic.AddUint64(&s.dropped, 1)
+	case OverflowDisconnect:
+		atomic.AddUint64(&s.dropped, 1)
+		s.stop(utils.ErrStreamOverflow)
+	}
+}
+
+func (s *EventStream) stop(err error) {
+	s.doneOnce.Do(func() {
+		if err != nil {
+			s.err.Store(err)
+		}
+		close(s.done)
+	})
+}
+
+// EventStream 订阅匹配 opts.Filter 的事件并解码为类型化事件, 写入独立的有界队列
+func (a *Amigo) EventStream(ctx context.Context, opts EventStreamOptions) *EventStream {
+	if opts.Buffer <= 0 {
+		opts.Buffer = DefaultStreamBuffer
+	}
+	queue := make(chan Event, opts.Buffer)
+	stream := &EventStream{
+		C:        queue,
+		queue:    queue,
+		overflow: opts.Overflow,
+		done:     make(chan struct{}),
+	}
+
+	sub := a.Subscribe(opts.Filter, func(event *parse.Event) {
+		typed, err := DecodeEvent(event)
+		if err != nil {
+			utils.Log.Errorf("event stream decode %s", err)
+			return
+		}
+		stream.push(typed)
+	})
+
+	wait := func() {
+		select {
+		case <-ctx.Done():
+		case <-a.closed:
+		case <-stream.done:
+		}
+		sub.Unsubscribe()
+		stream.stop(nil)
+
+		// push 退出后才能关闭队列
+		stream.mutex.Lock()
+		close(stream.queue)
+		stream.mutex.Unlock()
+	}
+	// 已关闭时 wait 立即返回, 直接结束 stream
+	if !a.spawn(wait) {
+		wait()
+	}
+	return stream
+}
+
+// Events 与 EventStream 相同, 只返回事件 channel
+func (a *Amigo) Events(ctx context.Context, opts EventStreamOptions) <-chan Event {
+	return a.EventStream(ctx, opts).C
+}
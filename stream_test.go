@@ -0,0 +1,82 @@
+package amigo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventsClose(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(a *Amigo, cancel context.CancelFunc)
+		// before 在 Events 之前关闭
+		before bool
+	}{
+		{
+			name:   "amigo closed before",
+			close:  func(a *Amigo, cancel context.CancelFunc) { a.Close(context.Background()) },
+			before: true,
+		},
+		{
+			name:  "amigo closed after",
+			close: func(a *Amigo, cancel context.CancelFunc) { a.Close(context.Background()) },
+		},
+		{
+			name:  "context canceled",
+			close: func(a *Amigo, cancel context.CancelFunc) { cancel() },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(&Settings{}, nil)
+			defer a.Close(context.Background())
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			if tt.before {
+				tt.close(a, cancel)
+			}
+			events := a.Events(ctx, EventStreamOptions{})
+			if !tt.before {
+				tt.close(a, cancel)
+			}
+
+			select {
+			case _, ok := <-events:
+				if ok {
+					t.Error("received an event")
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("events channel not closed")
+			}
+		})
+	}
+}
+
+// Events/onLogin 与 Close 并发时, Close 等待的 goroutine 不能在 wg.Wait 之后才登记
+func TestCloseConcurrentWithSpawn(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		a := New(&Settings{}, nil)
+		a.onLogin(func() {})
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				a.Events(context.Background(), EventStreamOptions{})
+				a.loginHooks.notify(nil)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			a.Close(context.Background())
+		}()
+		wg.Wait()
+		if a.spawn(func() {}) {
+			t.Fatal("spawn succeeded after Close")
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package amigo
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+)
+
+// EventFilter 订阅事件的过滤条件, 各条件同时满足时才调用 handler
+type EventFilter struct {
+	// Names 事件名, 支持通配符如 "Queue*", 为空匹配全部事件
+	Names []string
+	// Headers 头的值必须相等, 头名不区分大小写, 如 {"Queue": "sales"}
+	Headers map[string]string
+	// Match 自定义判断, 为 nil 时不判断
+	Match func(event *parse.Event) bool
+}
+
+// EventHandler 订阅事件的回调, 在消息处理 goroutine 中同步执行
+type EventHandler func(event *parse.Event)
+
+// Subscription Subscribe 返回的订阅句柄
+type Subscription interface {
+	// Unsubscribe 取消订阅, 重复调用无影响
+	Unsubscribe()
+}
+
+type subscription struct {
+	filter  EventFilter
+	handler EventHandler
+	subs    *subscriptions
+	// names filter.Names 中去重后的精确事件名, patterns 为其中的通配符
+	names    map[string]bool
+	patterns []string
+	once     sync.Once
+	removed  int32
+}
+
+// Unsubscribe implements Subscription
+func (s *subscription) Unsubscribe() {
+	s.once.Do(func() {
+		atomic.StoreInt32(&s.removed, 1)
+		s.subs.remove(s)
+	})
+}
+
+func (s *subscription) matches(event *parse.Event) bool {
+	// dispatch 可能持有取消前的列表
+	if atomic.LoadInt32(&s.removed) == 1 {
+		return false
+	}
+	for key, value := range s.filter.Headers {
+		if event.Get(key) != value {
+			return false
+		}
+	}
+	return s.filter.Match == nil || s.filter.Match(event)
+}
+
+// matchesPattern 通配符订阅的事件名匹配, 精确名称匹配的事件已按名称分发
+func (s *subscription) matchesPattern(name string) bool {
+	if len(s.names) == 0 && len(s.patterns) == 0 {
+		return true
+	}
+	if s.names[name] {
+		return false
+	}
+	for _, pattern := range s.patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// subscriptions 按事件名索引订阅, 含通配符或不限事件名的订阅另存一份
+// 列表在修改时整体替换, dispatch 无需复制
+type subscriptions struct {
+	mutex    sync.RWMutex
+	byName   map[string][]*subscription
+	wildcard []*subscription
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{byName: make(map[string][]*subscription)}
+}
+
+func isWildcard(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+func (s *subscriptions) add(sub *subscription) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if len(sub.patterns) > 0 || len(sub.names) == 0 {
+		s.wildcard = append(s.wildcard[:len(s.wildcard):len(s.wildcard)], sub)
+	}
+	for name := range sub.names {
+		list := s.byName[name]
+		s.byName[name] = append(list[:len(list):len(list)], sub)
+	}
+}
+
+func (s *subscriptions) remove(sub *subscription) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.wildcard = without(s.wildcard, sub)
+	for name := range sub.names {
+		if list := without(s.byName[name], sub); len(list) > 0 {
+			s.byName[name] = list
+		} else {
+			delete(s.byName, name)
+		}
+	}
+}
+
+func without(list []*subscription, sub *subscription) []*subscription {
+	result := make([]*subscription, 0, len(list))
+	for _, item := range list {
+		if item != sub {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func (s *subscriptions) dispatch(event *parse.Event) {
+	name := event.Get("Event")
+
+	s.mutex.RLock()
+	named := s.byName[name]
+	wildcard := s.wildcard
+	s.mutex.RUnlock()
+
+	for _, sub := range named {
+		if sub.matches(event) {
+			sub.handler(event)
+		}
+	}
+	for _, sub := range wildcard {
+		if !sub.matchesPattern(name) {
+			continue
+		}
+		if sub.matches(event) {
+			sub.handler(event)
+		}
+	}
+}
+
+// Subscribe 订阅匹配 filter 的事件, 返回的 Subscription 用于取消订阅
+// 精确的事件名按名称直接分发, 不会遍历其他订阅; 只有通配符需要逐个匹配.
+// 同一事件即使匹配多个名称也只调用一次 handler
+func (a *Amigo) Subscribe(filter EventFilter, handler EventHandler) Subscription {
+	sub := &subscription{
+		filter:  filter,
+		handler: handler,
+		subs:    a.subscriptions,
+		names:   make(map[string]bool),
+	}
+	for _, name := range filter.Names {
+		if !isWildcard(name) {
+			sub.names[name] = true
+			continue
+		}
+		duplicate := false
+		for _, pattern := range sub.patterns {
+			duplicate = duplicate || pattern == name
+		}
+		if !duplicate {
+			sub.patterns = append(sub.patterns, name)
+		}
+	}
+	a.subscriptions.add(sub)
+	return sub
+}
@@ -0,0 +1,97 @@
+package amigo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tqcenglish/amigo-go/pkg/parse"
+)
+
+func TestSubscribe(t *testing.T) {
+	events := []string{"QueueMemberAdded", "QueueCallerJoin", "Hangup", "Newchannel"}
+	tests := []struct {
+		name    string
+		filter  EventFilter
+		want    []string
+		byName  int
+		pattern bool
+	}{
+		{
+			name:    "all events",
+			filter:  EventFilter{},
+			want:    events,
+			pattern: true,
+		},
+		{
+			name:   "exact names",
+			filter: EventFilter{Names: []string{"Hangup", "Newchannel"}},
+			want:   []string{"Hangup", "Newchannel"},
+			byName: 2,
+		},
+		{
+			name:   "duplicate names",
+			filter: EventFilter{Names: []string{"Hangup", "Hangup", "Newchannel"}},
+			want:   []string{"Hangup", "Newchannel"},
+			byName: 2,
+		},
+		{
+			name:    "wildcard",
+			filter:  EventFilter{Names: []string{"Queue*", "Queue*"}},
+			want:    []string{"QueueMemberAdded", "QueueCallerJoin"},
+			pattern: true,
+		},
+		{
+			name:    "mixed exact and wildcard",
+			filter:  EventFilter{Names: []string{"Queue*", "Hangup"}},
+			want:    []string{"QueueMemberAdded", "QueueCallerJoin", "Hangup"},
+			byName:  1,
+			pattern: true,
+		},
+		{
+			name:    "exact name also matched by wildcard",
+			filter:  EventFilter{Names: []string{"Queue*", "QueueMemberAdded"}},
+			want:    []string{"QueueMemberAdded", "QueueCallerJoin"},
+			byName:  1,
+			pattern: true,
+		},
+		{
+			name:   "headers",
+			filter: EventFilter{Names: []string{"Hangup", "Newchannel"}, Headers: map[string]string{"Uniqueid": "1.2"}},
+			want:   []string{"Newchannel"},
+			byName: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Amigo{subscriptions: newSubscriptions()}
+			var got []string
+			sub := a.Subscribe(tt.filter, func(event *parse.Event) {
+				got = append(got, event.Get("Event"))
+			})
+
+			if n := len(a.subscriptions.byName); n != tt.byName {
+				t.Errorf("indexed names = %d, want %d", n, tt.byName)
+			}
+			if pattern := len(a.subscriptions.wildcard) > 0; pattern != tt.pattern {
+				t.Errorf("on wildcard list = %v, want %v", pattern, tt.pattern)
+			}
+
+			for _, name := range events {
+				uniqueid := "1.1"
+				if name == "Newchannel" {
+					uniqueid = "1.2"
+				}
+				a.subscriptions.dispatch(parse.NewEvent("Event: " + name + "\r\nUniqueid: " + uniqueid))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("delivered %v, want %v", got, tt.want)
+			}
+
+			sub.Unsubscribe()
+			if len(a.subscriptions.byName) != 0 || len(a.subscriptions.wildcard) != 0 {
+				t.Errorf("after Unsubscribe byName %v wildcard %d", a.subscriptions.byName, len(a.subscriptions.wildcard))
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package amigo
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"os"
+)
+
+// tlsConfig 根据 Settings 构建 TLS 配置
+func (s *Settings) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         s.TLSServerName,
+		InsecureSkipVerify: s.TLSInsecureSkipVerify,
+	}
+	if config.ServerName == "" {
+		config.ServerName = s.Host
+	}
+
+	if s.TLSCAFile != "" {
+		pem, err := os.ReadFile(s.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + s.TLSCAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if s.TLSCertFile != "" || s.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.TLSCertFile, s.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// dialTLS 建立 TLS 连接
+func (a *amiAdapter) dialTLS() (net.Conn, error) {
+	config, err := a.settings.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: a.dialTimeout}
+	return tls.DialWithDialer(dialer, "tcp", a.dialString, config)
+}
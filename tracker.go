@@ -0,0 +1,102 @@
+package amigo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tqcenglish/amigo-go/utils"
+)
+
+// tracker 各状态子系统共用的事件订阅、登录后重新同步、变更通知和关闭
+//
+// 子系统的状态由 mutex 保护. 事件处理函数通过 lock 修改状态, 并用 touch 登记改变的对象;
+// 重新同步的列表结果可能早于同步期间收到的事件, 合并时被 touch 过的对象以事件为准
+type tracker struct {
+	amigo *Amigo
+	name  string
+	subs  []Subscription
+
+	mutex  sync.RWMutex
+	closed bool
+	// touched 重新同步期间由事件改变的对象, 不在同步中时为 nil
+	touched map[string]bool
+	// cancel 取消进行中的重新同步, 不在同步中时为 nil
+	cancel context.CancelFunc
+
+	seedMutex sync.Mutex
+	watchers  watchers
+}
+
+// start 订阅 events, 每次登录后执行 seed, 已登录时立即执行
+func (t *tracker) start(a *Amigo, name string, events []string, handle EventHandler, seed func()) {
+	t.amigo = a
+	t.name = name
+	t.subs = append(t.subs, a.Subscribe(EventFilter{Names: events}, handle), a.onLogin(seed))
+}
+
+// close 取消订阅和重新同步, 保留最后的状态
+func (t *tracker) close() {
+	t.mutex.Lock()
+	t.closed = true
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.mutex.Unlock()
+	for _, sub := range t.subs {
+		sub.Unsubscribe()
+	}
+}
+
+// lock 获取写锁, 已关闭时不加锁并返回 false
+func (t *tracker) lock() bool {
+	t.mutex.Lock()
+	if t.closed {
+		t.mutex.Unlock()
+		return false
+	}
+	return true
+}
+
+// touch 登记 key 已被事件改变, 调用方持有写锁
+func (t *tracker) touch(keys ...string) {
+	if t.touched == nil {
+		return
+	}
+	for _, key := range keys {
+		t.touched[key] = true
+	}
+}
+
+// resync 串行执行一次重新同步: fetch 不持有锁查询 Asterisk, ctx 以 utils.ActionTimeout 为超时,
+// close 时取消. 成功后持有写锁调用 merge, touched 为 fetch 期间被事件改变的对象.
+// fetch 失败或已关闭时不调用 merge
+func (t *tracker) resync(fetch func(ctx context.Context) error, merge func(touched map[string]bool)) {
+	t.seedMutex.Lock()
+	defer t.seedMutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), utils.ActionTimeout*time.Second)
+	defer cancel()
+	if !t.lock() {
+		return
+	}
+	t.touched = make(map[string]bool)
+	t.cancel = cancel
+	t.mutex.Unlock()
+
+	err := fetch(ctx)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	touched := t.touched
+	t.touched = nil
+	t.cancel = nil
+	if t.closed {
+		return
+	}
+	if err != nil {
+		utils.Log.Errorf("%s resync %s", t.name, err)
+		return
+	}
+	merge(touched)
+}
@@ -27,6 +27,22 @@ const (
 
 var (
 	ErrNotConnected = errors.New("not connected to asterisk")
+	//ErrActionTimeout action 等待响应超时
+	ErrActionTimeout = errors.New("action response timeout")
+	//ErrClosed Amigo 已关闭
+	ErrClosed = errors.New("amigo closed")
+	//ErrStreamOverflow EventStream 队列已满被断开
+	ErrStreamOverflow = errors.New("event stream overflow")
+	//ErrPending action 尚未完成
+	ErrPending = errors.New("action response pending")
+	//ErrInvalidAction action 的头不合法, 如值中含有 CR/LF
+	ErrInvalidAction = errors.New("invalid action")
+	//ErrDBKeyNotFound AstDB 中不存在该 key
+	ErrDBKeyNotFound = errors.New("astdb key not found")
+	//ErrNoSuchChannel action 指定的通道不存在, 如已挂断
+	ErrNoSuchChannel = errors.New("no such channel")
+	//ErrPermissionDenied manager.conf 中该用户没有执行 action 的权限
+	ErrPermissionDenied = errors.New("permission denied")
 	//ErrEOM EOM error
 	ErrEOM = errors.New("eom")
 )
@@ -10,6 +10,8 @@ import (
 )
 
 //Marshall 构建 action
+//
+// Deprecated: 头的顺序不固定且不检查 CR/LF, 使用 amigo.Action
 func Marshall(action map[string]interface{}) string {
 	output := ""
 	for key, value := range action {
@@ -44,6 +46,8 @@ func NextID() string {
 }
 
 //SetField 反射设置值
+//
+// Deprecated: 使用 parse.Decode 按 ami tag 解码
 func SetField(obj interface{}, name string, value interface{}) error {
 	structValue := reflect.ValueOf(obj).Elem()
 	structFieldValue := structValue.FieldByName(name)
@@ -85,9 +89,6 @@ func EventComplete(event, list string) bool {
 	if strings.Contains(event, "Complete") {
 		return true
 	}
-	if strings.Contains(event, "DBGetResponse") {
-		return true
-	}
 	if list != "" && strings.Contains(list, "Complete") {
 		return true
 	}
@@ -0,0 +1,53 @@
+package amigo
+
+import "sync"
+
+// watchers 回调列表, notify 在调用方 goroutine 中依次执行, 注册和取消不影响进行中的 notify
+type watchers struct {
+	mutex sync.RWMutex
+	list  []*watcher
+}
+
+type watcher struct {
+	fn   func(interface{})
+	list *watchers
+	once sync.Once
+}
+
+// Unsubscribe implements Subscription
+func (w *watcher) Unsubscribe() {
+	w.once.Do(func() {
+		w.list.mutex.Lock()
+		defer w.list.mutex.Unlock()
+		list := make([]*watcher, 0, len(w.list.list))
+		for _, item := range w.list.list {
+			if item != w {
+				list = append(list, item)
+			}
+		}
+		w.list.list = list
+	})
+}
+
+func (l *watchers) add(fn func(interface{})) Subscription {
+	w := &watcher{fn: fn, list: l}
+	l.mutex.Lock()
+	l.list = append(l.list[:len(l.list):len(l.list)], w)
+	l.mutex.Unlock()
+	return w
+}
+
+func (l *watchers) len() int {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return len(l.list)
+}
+
+func (l *watchers) notify(v interface{}) {
+	l.mutex.RLock()
+	list := l.list
+	l.mutex.RUnlock()
+	for _, w := range list {
+		w.fn(v)
+	}
+}